@@ -0,0 +1,60 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func newAdminHTTPServer(configs *Configs, router *mux.Router) *http.Server {
+	return &http.Server{
+		Addr:         listenAddress(configs, configs.AdminPort),
+		Handler:      router,
+		WriteTimeout: configs.WriteTimeout,
+		ReadTimeout:  configs.ReadTimeout,
+		ErrorLog:     errorLog(configs.Logger),
+	}
+}
+
+// startAdminServer serves the built-in endpoints on the admin port until the admin server is stopped.
+func (s *ServerImpl) startAdminServer() error {
+	l, err := net.Listen("tcp", s.adminServer.Addr)
+	if err != nil {
+		return &StartError{Addr: s.adminServer.Addr, Err: err}
+	}
+	if err := s.adminServer.Serve(l); err != http.ErrServerClosed {
+		return &StartError{Addr: l.Addr().String(), Err: err}
+	}
+	return http.ErrServerClosed
+}
+
+// stopAdminServer stops the admin server once the main server stopped, so the built-in endpoints stay available while
+// the main server drains.
+func (s *ServerImpl) stopAdminServer(behavior ShutdownBehavior, ctx context.Context) error {
+	if behavior == Immediate {
+		return s.adminServer.Close()
+	}
+	return s.adminServer.Shutdown(ctx)
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServerWithAdminPortShouldServeBuiltInEndpointsOnlyOnTheAdminPort(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/users").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	configs := getTestConfigs()
+	configs.AdminPort = getTestConfigs().Port
+	server := New(configs, router)
+
+	startError := make(chan error)
+	go func() {
+		startError <- server.Start()
+	}()
+	waitForListener(t, configs.Port)
+	waitForListener(t, configs.AdminPort)
+
+	testEndpoint(t, configs.AdminPort, DefaultPingEndpoint, 200)
+	testEndpoint(t, configs.AdminPort, DefaultHealthcheckEndpoint, 200)
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 404)
+	testEndpoint(t, configs.Port, "/users", 200)
+	testEndpoint(t, configs.AdminPort, "/users", 404)
+
+	if err := server.Stop(); err != nil {
+		t.Errorf("Expected: success; Got: %s", err.Error())
+	}
+	if err := <-startError; err != nil {
+		t.Errorf("Expected: success; Got: %s", err.Error())
+	}
+	for _, port := range []int{configs.Port, configs.AdminPort} {
+		if resp, err := http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, port, DefaultPingEndpoint)); err == nil {
+			resp.Body.Close()
+			t.Errorf("Expected: port %d closed; Got: %d", port, resp.StatusCode)
+		}
+	}
+}
+
+func TestServerWithUnavailableAdminPortShouldReturnStartError(t *testing.T) {
+	configs := getTestConfigs()
+	configs.AdminPort = -1
+	server := New(configs, mux.NewRouter())
+
+	var startErr *StartError
+	if err := server.Start(); !errors.As(err, &startErr) {
+		t.Fatalf("Expected: StartError; Got: %v", err)
+	}
+	if startErr.Addr != ":-1" {
+		t.Errorf("Expected: :-1; Got: %s", startErr.Addr)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	// DefaultAutocertChallengePort holds the default port the ACME HTTP-01 challenges are served on.
+	DefaultAutocertChallengePort = 80
+
+	// acmeTLSProto holds the ALPN protocol of the ACME TLS-ALPN-01 challenges.
+	acmeTLSProto = "acme-tls/1"
+)
+
+// CertificateManager provisions TLS certificates on demand and answers the ACME challenges proving the domains
+// ownership. It is implemented by the *autocert.Manager of golang.org/x/crypto/acme/autocert.
+type CertificateManager interface {
+	GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+	HTTPHandler(fallback http.Handler) http.Handler
+}
+
+// AutocertConfig holds the automatic certificate provisioning configs.
+// Domains holds the domains certificates are provisioned for from Let's Encrypt, accepting its terms of service.
+// CacheDir holds the directory the provisioned certificates are cached in across restarts. They're only kept in memory
+// when empty, requesting them again on each start.
+// Email holds the contact email of the ACME account, notified of the certificates' problems and expiration.
+// Manager holds a certificate manager replacing the one built from Domains, CacheDir and Email, which must then be
+// left empty.
+// ChallengePort holds the port the ACME HTTP-01 challenges are served on, redirecting all other requests to HTTPS.
+// Defaults to DefaultAutocertChallengePort when zero. A negative port disables the challenge server, leaving only the
+// TLS-ALPN-01 challenges.
+type AutocertConfig struct {
+	Domains       []string
+	CacheDir      string
+	Email         string
+	Manager       CertificateManager
+	ChallengePort int
+}
+
+func (c *AutocertConfig) validate(configs *Configs) error {
+	if c.Manager == nil && len(c.Domains) == 0 {
+		return errors.New("server: autocert requires domains or a certificate manager")
+	}
+	if c.Manager != nil && (len(c.Domains) > 0 || c.CacheDir != "" || c.Email != "") {
+		return errors.New("server: autocert domains, cache directory and email can't be used along with a certificate manager")
+	}
+	if configs.CertFile != "" || configs.KeyFile != "" || configs.TLSConfig != nil {
+		return errors.New("server: autocert can't be used along with certificate files or a TLS config")
+	}
+	return nil
+}
+
+// certificateManager returns the Manager of c, or an *autocert.Manager provisioning the certificates of its Domains.
+func (c *AutocertConfig) certificateManager() CertificateManager {
+	if c.Manager != nil {
+		return c.Manager
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(c.Domains...),
+		Email:      c.Email,
+	}
+	if c.CacheDir != "" {
+		manager.Cache = autocert.DirCache(c.CacheDir)
+	}
+	return manager
+}
+
+// newAutocertTLSConfig returns the TLS config serving the certificates of the autocert manager and answering the
+// TLS-ALPN-01 challenges.
+func (s *ServerImpl) newAutocertTLSConfig() (*tls.Config, error) {
+	config, err := s.newTLSConfig(s.certificateManager.GetCertificate)
+	if err != nil {
+		return nil, err
+	}
+	config.NextProtos = append(config.NextProtos, acmeTLSProto)
+	return config, nil
+}
+
+func newChallengeHTTPServer(configs *Configs, manager CertificateManager) *http.Server {
+	port := configs.Autocert.ChallengePort
+	if port == 0 {
+		port = DefaultAutocertChallengePort
+	}
+	return &http.Server{
+		Addr:         listenAddress(configs, port),
+		Handler:      manager.HTTPHandler(nil),
+		WriteTimeout: configs.WriteTimeout,
+		ReadTimeout:  configs.ReadTimeout,
+		ErrorLog:     errorLog(configs.Logger),
+	}
+}
+
+// startChallengeServer serves the ACME HTTP-01 challenges until the challenge server is stopped.
+func (s *ServerImpl) startChallengeServer() error {
+	l, err := net.Listen("tcp", s.challengeServer.Addr)
+	if err != nil {
+		return &StartError{Addr: s.challengeServer.Addr, Err: err}
+	}
+	if err := s.challengeServer.Serve(l); err != http.ErrServerClosed {
+		return &StartError{Addr: l.Addr().String(), Err: err}
+	}
+	return http.ErrServerClosed
+}
+
+// stopChallengeServer stops the challenge server once the main server stopped.
+func (s *ServerImpl) stopChallengeServer(behavior ShutdownBehavior, ctx context.Context) error {
+	if behavior == Immediate {
+		return s.challengeServer.Close()
+	}
+	return s.challengeServer.Shutdown(ctx)
+}
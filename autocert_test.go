@@ -0,0 +1,178 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// stubCertificateManager serves a fixed certificate and answers the HTTP-01 challenge paths with 200.
+type stubCertificateManager struct {
+	cert  tls.Certificate
+	calls int32
+}
+
+func (m *stubCertificateManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	atomic.AddInt32(&m.calls, 1)
+	return &m.cert, nil
+}
+
+func (m *stubCertificateManager) HTTPHandler(fallback http.Handler) http.Handler {
+	router := mux.NewRouter()
+	router.PathPrefix("/.well-known/acme-challenge/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	return router
+}
+
+func TestServerWithAutocertShouldServeTheManagerCertificatesAndChallenges(t *testing.T) {
+	testCert := newTestCert(t, 1, nil)
+	cert, err := tls.X509KeyPair(testCert.certPEM, testCert.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := &stubCertificateManager{cert: cert}
+	configs := getTestConfigs()
+	configs.Autocert = &AutocertConfig{Manager: manager, ChallengePort: getTestConfigs().Port}
+	server := New(configs, mux.NewRouter())
+
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+	waitForListener(t, configs.Autocert.ChallengePort)
+
+	if serial := servedCertificateSerial(t, configs.Port); serial != 1 {
+		t.Errorf("Expected: manager certificate served; Got: certificate %d", serial)
+	}
+	if calls := atomic.LoadInt32(&manager.calls); calls == 0 {
+		t.Error("Expected: manager GetCertificate called; Got: not called")
+	}
+	if protos := server.GetHTTPServer().TLSConfig.NextProtos; !containsString(protos, acmeTLSProto) {
+		t.Errorf("Expected: %s negotiable; Got: %v", acmeTLSProto, protos)
+	}
+	testEndpoint(t, configs.Autocert.ChallengePort, "/.well-known/acme-challenge/token", 200)
+}
+
+func TestServerWithAutocertAndCertificateFilesShouldReturnErrorOnStart(t *testing.T) {
+	configs := getTestConfigs()
+	configs.Autocert = &AutocertConfig{Manager: &stubCertificateManager{}, ChallengePort: -1}
+	configs.CertFile, configs.KeyFile = "server.crt", "server.key"
+	server := New(configs, mux.NewRouter())
+
+	if err := server.Start(); err == nil {
+		t.Error("Expected: error as autocert and certificate files are both set; Got: success")
+	}
+}
+
+func TestServerWithAutocertDomainsShouldServeTheCachedCertificates(t *testing.T) {
+	dir := newTempDir(t)
+	defer os.RemoveAll(dir)
+	writeAutocertCache(t, dir, "example.com", 7)
+	configs := getTestConfigs()
+	configs.Autocert = &AutocertConfig{
+		Domains:       []string{"example.com"},
+		CacheDir:      dir,
+		Email:         "admin@example.com",
+		ChallengePort: -1,
+	}
+	server := New(configs, mux.NewRouter())
+
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+
+	conn, err := tls.Dial("tcp", fmt.Sprintf("localhost:%d", configs.Port), &tls.Config{ServerName: "example.com", InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if serial := conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(); serial != 7 {
+		t.Errorf("Expected: cached certificate served; Got: certificate %d", serial)
+	}
+	if _, err := tls.Dial("tcp", fmt.Sprintf("localhost:%d", configs.Port), &tls.Config{ServerName: "other.com", InsecureSkipVerify: true}); err == nil {
+		t.Error("Expected: handshake rejected for a domain not in Domains; Got: success")
+	}
+}
+
+func TestServerWithAutocertWithoutManagerShouldReturnErrorOnStart(t *testing.T) {
+	configs := getTestConfigs()
+	configs.Autocert = &AutocertConfig{}
+	server := New(configs, mux.NewRouter())
+
+	if err := server.Start(); err == nil {
+		t.Error("Expected: error as the domains and certificate manager are missing; Got: success")
+	}
+}
+
+func TestServerWithAutocertDomainsAndManagerShouldReturnErrorOnStart(t *testing.T) {
+	configs := getTestConfigs()
+	configs.Autocert = &AutocertConfig{Domains: []string{"example.com"}, Manager: &stubCertificateManager{}, ChallengePort: -1}
+	server := New(configs, mux.NewRouter())
+
+	if err := server.Start(); err == nil {
+		t.Error("Expected: error as the domains and certificate manager are both set; Got: success")
+	}
+}
+
+// writeAutocertCache writes a certificate for domain, valid for a year, into the autocert cache directory dir.
+func writeAutocertCache(t *testing.T, dir, domain string, serial int64) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{domain},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	if err := ioutil.WriteFile(filepath.Join(dir, domain), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
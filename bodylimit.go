@@ -0,0 +1,76 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// limitedBody wraps a request body limited by http.MaxBytesReader, recording whether the limit was exceeded.
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// limitRequestBody replies 413 to the requests with bodies larger than the MaxRequestBodyBytes, or 417 to the ones
+// expecting 100-continue so clients don't send the body. Requests without a Content-Length are replied with 413 once
+// the handler reads past the limit, unless the handler writes a response.
+func (s *ServerImpl) limitRequestBody(next http.Handler) http.Handler {
+	exemptPaths := make(map[string]bool, len(s.Configs.MaxRequestBodyExemptPaths))
+	for _, path := range s.Configs.MaxRequestBodyExemptPaths {
+		exemptPaths[path] = true
+	}
+	maxBytes := s.Configs.MaxRequestBodyBytes
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exemptPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.ContentLength > maxBytes {
+			if expectsContinue(r) {
+				s.writeError(w, http.StatusExpectationFailed)
+				return
+			}
+			s.writeError(w, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		body := &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, maxBytes)}
+		r.Body = body
+		rw := newResponseWriter(w)
+		next.ServeHTTP(rw, r)
+		if body.exceeded && !rw.wroteHeader {
+			s.writeError(w, http.StatusRequestEntityTooLarge)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServerWithMaxRequestBodyBytesShouldReplyOversizedBodiesWith413(t *testing.T) {
+	configs := getTestConfigs()
+	configs.MaxRequestBodyBytes = 10
+
+	runTestServer(t, configs, newReadBodyRouter(), true, nil, func(s Server) {
+		expectPostStatus(t, configs.Port, "/upload", bytes.NewReader(make([]byte, 10)), http.StatusOK)
+		expectPostStatus(t, configs.Port, "/upload", bytes.NewReader(make([]byte, 11)), http.StatusRequestEntityTooLarge)
+		// Without a Content-Length the limit is only detected when the handler reads the body.
+		expectPostStatus(t, configs.Port, "/upload", ioutil.NopCloser(strings.NewReader(strings.Repeat("a", 11))), http.StatusRequestEntityTooLarge)
+	})
+}
+
+func TestServerWithMaxRequestBodyBytesShouldNotLimitExemptPaths(t *testing.T) {
+	configs := getTestConfigs()
+	configs.MaxRequestBodyBytes = 10
+	configs.MaxRequestBodyExemptPaths = []string{"/upload"}
+
+	runTestServer(t, configs, newReadBodyRouter(), true, nil, func(s Server) {
+		expectPostStatus(t, configs.Port, "/upload", bytes.NewReader(make([]byte, 11)), http.StatusOK)
+	})
+}
+
+// newReadBodyRouter returns a router whose /upload route reads the whole request body, replying nothing on failure.
+func newReadBodyRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.Path("/upload").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err == nil {
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	return router
+}
+
+func expectPostStatus(t *testing.T, port int, path string, body io.Reader, status int) {
+	t.Helper()
+	resp, err := http.Post(fmt.Sprintf("%s:%d%s", testServerEndpoint, port, path), "application/octet-stream", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != status {
+		t.Errorf("Expected: %d; Got: %d", status, resp.StatusCode)
+	}
+}
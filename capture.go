@@ -0,0 +1,110 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// debugCapture holds a path prefix whose request and response bodies are logged for debugging.
+type debugCapture struct {
+	pathPrefix string
+	maxBytes   int
+}
+
+// RegisterDebugCapture logs the request and response bodies of the requests to the paths starting with pathPrefix,
+// truncated to maxBytes each, along with their full sizes. The bodies are still served in full and streamed responses
+// are still flushed as they're written. Meant for troubleshooting, as the bodies may hold sensitive data.
+func (s *ServerImpl) RegisterDebugCapture(pathPrefix string, maxBytes int) {
+	s.debugCaptures = append(s.debugCaptures, debugCapture{pathPrefix: pathPrefix, maxBytes: maxBytes})
+}
+
+// captureBodies logs the bodies of the requests matching a debug capture once they're served.
+func (s *ServerImpl) captureBodies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capture, ok := s.debugCapture(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body := &captureReader{ReadCloser: r.Body, captured: capturedBody{max: capture.maxBytes}}
+		r.Body = body
+		cw := &captureWriter{responseWriter: newResponseWriter(w), captured: capturedBody{max: capture.maxBytes}}
+		next.ServeHTTP(cw, r)
+		s.logf("server: DEBUG %s %s request body (%d bytes) %q, response %d body (%d bytes) %q",
+			r.Method, r.URL.Path, body.captured.size, body.captured.buf.Bytes(),
+			cw.status, cw.captured.size, cw.captured.buf.Bytes())
+	})
+}
+
+// debugCapture returns the first debug capture matching path.
+func (s *ServerImpl) debugCapture(path string) (debugCapture, bool) {
+	for _, capture := range s.debugCaptures {
+		if strings.HasPrefix(path, capture.pathPrefix) {
+			return capture, true
+		}
+	}
+	return debugCapture{}, false
+}
+
+// capturedBody keeps the first max bytes of a body, counting its full size.
+type capturedBody struct {
+	buf  bytes.Buffer
+	max  int
+	size int64
+}
+
+func (c *capturedBody) write(b []byte) {
+	c.size += int64(len(b))
+	if remaining := c.max - c.buf.Len(); remaining > 0 {
+		if len(b) > remaining {
+			b = b[:remaining]
+		}
+		c.buf.Write(b)
+	}
+}
+
+// captureReader captures the request body as the handler reads it.
+type captureReader struct {
+	io.ReadCloser
+	captured capturedBody
+}
+
+func (r *captureReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.captured.write(p[:n])
+	return n, err
+}
+
+// captureWriter captures the response body as the handler writes it, passing it through to the wrapped writer.
+type captureWriter struct {
+	*responseWriter
+	captured capturedBody
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	n, err := w.responseWriter.Write(b)
+	w.captured.write(b[:n])
+	return n, err
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestDebugCaptureShouldLogTheTruncatedBodiesOfTheCapturedPaths(t *testing.T) {
+	router := mux.NewRouter()
+	router.PathPrefix("/api/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("received " + string(body)))
+	})
+	logs := make(chanLogger, 10)
+	configs := getTestConfigs()
+	configs.Logger = logs
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterDebugCapture("/api/", 8)
+		},
+		func(s Server) {
+			expectPostStatus(t, configs.Port, "/api/items", strings.NewReader("0123456789"), http.StatusCreated)
+			select {
+			case line := <-logs:
+				expected := `server: DEBUG POST /api/items request body (10 bytes) "01234567", response 201 body (19 bytes) "received"`
+				if line != expected {
+					t.Errorf("Expected: %s; Got: %s", expected, line)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Expected: bodies logged; Got: nothing logged")
+			}
+
+			status, body := getBody(t, configs.Port, DefaultPingEndpoint)
+			if status != 200 || body != "" {
+				t.Errorf("Expected: 200; Got: %d %s", status, body)
+			}
+			select {
+			case line := <-logs:
+				t.Errorf("Expected: uncaptured paths not logged; Got: %s", line)
+			default:
+			}
+		})
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultCompressionMinSize holds the default minimum size in bytes of the responses compressed.
+	DefaultCompressionMinSize = 1024
+)
+
+var (
+	// DefaultCompressionContentTypes holds the content types compressed when CompressionConfig.ContentTypes is not set.
+	DefaultCompressionContentTypes = []string{"text/*", "application/json", "application/javascript", "application/xml", "image/svg+xml"}
+)
+
+// CompressionConfig holds the response compression configs.
+// Level holds the gzip compression level, from gzip.BestSpeed to gzip.BestCompression. Zero means gzip.DefaultCompression.
+// ContentTypes holds the media types of the responses compressed, such as "application/json" or "text/*". Defaults to
+// DefaultCompressionContentTypes.
+// MinSize holds the minimum size in bytes of the responses compressed. Defaults to DefaultCompressionMinSize.
+type CompressionConfig struct {
+	Level        int
+	ContentTypes []string
+	MinSize      int
+}
+
+// validate returns an error if the compression level is not supported by gzip.
+func (c *CompressionConfig) validate() error {
+	_, err := gzip.NewWriterLevel(nil, c.level())
+	return err
+}
+
+func (c *CompressionConfig) level() int {
+	if c.Level == 0 {
+		return gzip.DefaultCompression
+	}
+	return c.Level
+}
+
+func (c *CompressionConfig) minSize() int {
+	if c.MinSize <= 0 {
+		return DefaultCompressionMinSize
+	}
+	return c.MinSize
+}
+
+// isContentTypeCompressed returns whether the responses of contentType should be compressed.
+func (c *CompressionConfig) isContentTypeCompressed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	contentTypes := c.ContentTypes
+	if len(contentTypes) == 0 {
+		contentTypes = DefaultCompressionContentTypes
+	}
+	for _, compressed := range contentTypes {
+		compressed = strings.ToLower(compressed)
+		if compressed == mediaType || strings.HasSuffix(compressed, "/*") && strings.HasPrefix(mediaType, compressed[:len(compressed)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// compress gzip-compresses the responses to the clients accepting gzip whose content type is configured to be compressed.
+// Responses are buffered up to the MinSize to leave tiny responses uncompressed.
+func (s *ServerImpl) compress(next http.Handler) http.Handler {
+	c := s.Configs.Compression
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipWriter{ResponseWriter: w, config: c, status: http.StatusOK}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip returns whether the Accept-Encoding header of r accepts gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(encoding, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if name != "gzip" && name != "*" {
+			continue
+		}
+		rejected := false
+		for _, param := range parts[1:] {
+			param = strings.Replace(param, " ", "", -1)
+			if param == "q=0" || strings.HasPrefix(param, "q=0.") && strings.Trim(param[4:], "0") == "" {
+				rejected = true
+			}
+		}
+		if !rejected {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipWriter buffers the response until either MinSize bytes are written, the response is flushed or the handler
+// returns, then decides whether to compress it based on its headers and content type.
+type gzipWriter struct {
+	http.ResponseWriter
+	config      *CompressionConfig
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	gz          *gzip.Writer
+}
+
+// WriteHeader records the status code, which is written once it's decided whether to compress the response.
+func (w *gzipWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.status = code
+	w.wroteHeader = true
+	if code < 200 || code == http.StatusNoContent || code == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+// Write buffers b until it's decided whether to compress the response, then writes it to the chosen writer.
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		return w.write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.config.minSize() {
+		if err := w.decide(w.compressible()); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush decides whether to compress the response if still undecided and flushes the compressed and wrapped writers.
+func (w *gzipWriter) Flush() {
+	if !w.decided {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		w.decide(w.compressible())
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, allowing http.ResponseController to reach it.
+func (w *gzipWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close writes the buffered response, if still undecided, and terminates the gzip stream.
+func (w *gzipWriter) close() {
+	if !w.decided && w.wroteHeader {
+		w.decide(len(w.buf) >= w.config.minSize() && w.compressible())
+	}
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
+
+// compressible returns whether the response headers allow compressing the response.
+func (w *gzipWriter) compressible() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	if _, ok := h["Content-Type"]; !ok {
+		// Sniff the content type now, as net/http would otherwise sniff the compressed bytes.
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	return w.config.isContentTypeCompressed(h.Get("Content-Type"))
+}
+
+// decide writes the headers, compressing the response if compress is set, and the buffered content.
+func (w *gzipWriter) decide(compress bool) error {
+	w.decided = true
+	if compress {
+		h := w.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", "gzip")
+		w.gz, _ = gzip.NewWriterLevel(w.ResponseWriter, w.config.level())
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	_, err := w.write(buf)
+	return err
+}
+
+func (w *gzipWriter) write(b []byte) (int, error) {
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServerWithCompressionShouldCompressLargeJSONResponses(t *testing.T) {
+	items := make([]string, 1000)
+	for i := range items {
+		items[i] = fmt.Sprintf("item-%d", i)
+	}
+	router := mux.NewRouter()
+	router.Path("/items").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(items)
+	})
+	configs := getTestConfigs()
+	configs.Compression = &CompressionConfig{ContentTypes: []string{"application/json"}}
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		resp := getCompressionTestResponse(t, configs.Port, "/items")
+		defer resp.Body.Close()
+		expectHeader(t, resp, "Content-Encoding", "gzip")
+		expectHeader(t, resp, "Vary", "Accept-Encoding")
+
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded []string
+		if err := json.NewDecoder(gz).Decode(&decoded); err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded) != len(items) || decoded[999] != items[999] {
+			t.Errorf("Expected: %d items; Got: %d", len(items), len(decoded))
+		}
+	})
+}
+
+func TestServerWithCompressionShouldNotCompressTinyOrUnlistedResponses(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/tiny").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	})
+	router.Path("/image").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(make([]byte, 4096))
+	})
+	router.Path("/compressed").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "br")
+		w.Write(make([]byte, 4096))
+	})
+	configs := getTestConfigs()
+	configs.Compression = &CompressionConfig{}
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		for path, encoding := range map[string]string{"/tiny": "", "/image": "", "/compressed": "br"} {
+			resp := getCompressionTestResponse(t, configs.Port, path)
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			expectHeader(t, resp, "Content-Encoding", encoding)
+			if path == "/tiny" && string(body) != `{"ok":true}` {
+				t.Errorf("Expected: %s; Got: %s", `{"ok":true}`, body)
+			}
+		}
+	})
+}
+
+func TestServerWithCompressionShouldNotCompressForClientsNotAcceptingGzip(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/text").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("text ", 1000)))
+	})
+	configs := getTestConfigs()
+	configs.Compression = &CompressionConfig{}
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		resp := doRequest(t, "GET", configs.Port, "/text", http.Header{"Accept-Encoding": {"gzip;q=0, identity"}})
+		expectHeader(t, resp, "Content-Encoding", "")
+	})
+}
+
+func TestServerWithInvalidCompressionLevelShouldReturnErrorOnStart(t *testing.T) {
+	configs := getTestConfigs()
+	configs.Compression = &CompressionConfig{Level: 42}
+	server := New(configs, mux.NewRouter())
+
+	if err := server.Start(); err == nil {
+		t.Error("Expected: error as the compression level is not valid; Got: success")
+	}
+}
+
+// getCompressionTestResponse requests path accepting gzip. The caller must close the response body, which isn't
+// transparently decompressed as the Accept-Encoding header is set explicitly.
+func getCompressionTestResponse(t *testing.T, port int, path string) *http.Response {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s:%d%s", testServerEndpoint, port, path), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
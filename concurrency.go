@@ -0,0 +1,82 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import "net/http"
+
+// concurrencyLimiter bounds the number of requests served at once by MaxConcurrentRequests or WorkerPoolSize, queueing
+// up to WorkerQueueDepth of the requests received while all of its slots are busy. Handlers keep running on the
+// goroutine net/http serves the connection on: handing them over to worker goroutines would leave that goroutine
+// waiting for the worker anyway.
+type concurrencyLimiter struct {
+	slots chan struct{}
+	// admitted holds a slot per request being served or queued.
+	admitted chan struct{}
+}
+
+func newConcurrencyLimiter(size, queueDepth int) *concurrencyLimiter {
+	if queueDepth < 0 {
+		queueDepth = 0
+	}
+	return &concurrencyLimiter{
+		slots:    make(chan struct{}, size),
+		admitted: make(chan struct{}, size+queueDepth),
+	}
+}
+
+// queued returns the number of requests waiting for a slot.
+func (l *concurrencyLimiter) queued() int {
+	return len(l.admitted) - len(l.slots)
+}
+
+// concurrencyRetryAfter holds the Retry-After seconds replied to the requests rejected by the concurrency limiter.
+const concurrencyRetryAfter = "1"
+
+// limitConcurrentRequests serves the requests on the concurrency limiter slots, replying 503 to the ones received while
+// its queue is full. Queued requests whose client goes away leave the queue without a reply. The requests to the
+// built-in endpoints are never limited, so probes keep working under load.
+func (s *ServerImpl) limitConcurrentRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.isBuiltinEndpoint(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		select {
+		case s.concurrencyLimiter.admitted <- struct{}{}:
+		default:
+			w.Header().Set("Retry-After", concurrencyRetryAfter)
+			s.writeError(w, http.StatusServiceUnavailable)
+			return
+		}
+		defer func() {
+			<-s.concurrencyLimiter.admitted
+		}()
+		select {
+		case s.concurrencyLimiter.slots <- struct{}{}:
+		case <-r.Context().Done():
+			return
+		}
+		defer func() {
+			<-s.concurrencyLimiter.slots
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServerWithMaxConcurrentRequestsShouldReplyRequestsAboveTheLimitWith503(t *testing.T) {
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	router := mux.NewRouter()
+	router.Path(slowEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+	configs := getTestConfigs()
+	configs.MaxConcurrentRequests = 2
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		statuses := make(chan int, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				resp, err := http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, slowEndpoint))
+				if err != nil {
+					statuses <- 0
+					return
+				}
+				resp.Body.Close()
+				statuses <- resp.StatusCode
+			}()
+		}
+		<-started
+		<-started
+
+		resp := doRequest(t, "GET", configs.Port, slowEndpoint, nil)
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Expected: 503; Got: %d", resp.StatusCode)
+		}
+		expectHeader(t, resp, "Retry-After", "1")
+		testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+		testEndpoint(t, configs.Port, DefaultHealthcheckEndpoint, 200)
+
+		close(release)
+		for i := 0; i < 2; i++ {
+			if status := <-statuses; status != 200 {
+				t.Errorf("Expected: 200; Got: %d", status)
+			}
+		}
+	})
+}
+
+func TestServerWithMaxConcurrentRequestsAndWorkerPoolShouldReturnErrorOnStart(t *testing.T) {
+	configs := getTestConfigs()
+	configs.MaxConcurrentRequests = 2
+	configs.WorkerPoolSize = 2
+	server := New(configs, mux.NewRouter())
+
+	if err := server.Start(); err == nil {
+		t.Error("Expected: error as MaxConcurrentRequests and WorkerPoolSize are both set; Got: success")
+	}
+}
+
+func TestServerWithWorkerPoolShouldQueueRequestsAndReply503OnceTheQueueIsFull(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	router := mux.NewRouter()
+	router.Path(slowEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+	configs := getTestConfigs()
+	configs.WorkerPoolSize = 1
+	configs.WorkerQueueDepth = 1
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		statuses := make(chan int, 2)
+		get := func() {
+			resp, err := http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, slowEndpoint))
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}
+		go get()
+		<-started
+		go get()
+		for s.Stats().Queued < 1 {
+			time.Sleep(time.Millisecond)
+		}
+
+		resp := doRequest(t, "GET", configs.Port, slowEndpoint, nil)
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Expected: 503; Got: %d", resp.StatusCode)
+		}
+		expectHeader(t, resp, "Retry-After", "1")
+		testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+		if len(started) != 0 {
+			t.Error("Expected: the queued request to wait for the worker; Got: started")
+		}
+
+		close(release)
+		for i := 0; i < 2; i++ {
+			if status := <-statuses; status != 200 {
+				t.Errorf("Expected: 200; Got: %d", status)
+			}
+		}
+	})
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// connTracker tracks the state of the connections served by the HTTP server, along with the connections hijacked from
+// it, which the HTTP server no longer tracks.
+type connTracker struct {
+	mu            sync.Mutex
+	conns         map[net.Conn]http.ConnState
+	hijacked      map[*hijackedConn]bool
+	hijackedGroup requestGroup
+}
+
+// track records the new state of c, forgetting it once it's closed or hijacked.
+func (t *connTracker) track(c net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch state {
+	case http.StateHijacked, http.StateClosed:
+		delete(t.conns, c)
+	default:
+		if t.conns == nil {
+			t.conns = make(map[net.Conn]http.ConnState)
+		}
+		t.conns[c] = state
+	}
+}
+
+// closeIdle closes all idle connections.
+func (t *connTracker) closeIdle() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for c, state := range t.conns {
+		if state == http.StateIdle {
+			c.Close()
+			delete(t.conns, c)
+		}
+	}
+}
+
+// hijack records c as hijacked until it's closed.
+func (t *connTracker) hijack(c *hijackedConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.hijacked == nil {
+		t.hijacked = make(map[*hijackedConn]bool)
+	}
+	t.hijacked[c] = true
+	t.hijackedGroup.add()
+}
+
+// forgetHijacked forgets the closed hijacked connection c.
+func (t *connTracker) forgetHijacked(c *hijackedConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.hijacked[c] {
+		delete(t.hijacked, c)
+		t.hijackedGroup.done()
+	}
+}
+
+// closeHijacked closes all hijacked connections, returning how many were still open.
+func (t *connTracker) closeHijacked() int {
+	t.mu.Lock()
+	conns := make([]*hijackedConn, 0, len(t.hijacked))
+	for c := range t.hijacked {
+		conns = append(conns, c)
+	}
+	t.mu.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+	return len(conns)
+}
+
+// hijackWriter records the connections hijacked through it in the connection tracker.
+type hijackWriter struct {
+	http.ResponseWriter
+	conns *connTracker
+}
+
+// Hijack hijacks the connection of the wrapped writer, tracking it until it's closed.
+func (w *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	c := &hijackedConn{Conn: conn, conns: w.conns}
+	w.conns.hijack(c)
+	return c, rw, nil
+}
+
+// Flush flushes the wrapped writer if it supports flushing.
+func (w *hijackWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, allowing http.ResponseController to reach it.
+func (w *hijackWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// hijackedConn wraps a hijacked connection, forgetting it once closed.
+type hijackedConn struct {
+	net.Conn
+	conns *connTracker
+}
+
+// Close closes the connection and forgets it.
+func (c *hijackedConn) Close() error {
+	c.conns.forgetHijacked(c)
+	return c.Conn.Close()
+}
+
+// CloseIdleConnections closes the idle keep-alive connections without shutting down the server. Connections serving a
+// request are unaffected, and the clients of the closed connections reconnect on their next request.
+func (s *ServerImpl) CloseIdleConnections() {
+	s.conns.closeIdle()
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCloseIdleConnectionsShouldCloseIdleKeepAliveConnections(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	idle := make(chan string, 10)
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterConnStateHandler(func(c net.Conn, state http.ConnState) {
+				if state == http.StateIdle {
+					idle <- c.RemoteAddr().String()
+				}
+			})
+		},
+		func(s Server) {
+			conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", configs.Port))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: localhost\r\n\r\n", DefaultPingEndpoint)
+			reader := bufio.NewReader(conn)
+			resp, err := http.ReadResponse(reader, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			for addr := ""; addr != conn.LocalAddr().String(); {
+				select {
+				case addr = <-idle:
+				case <-time.After(time.Second):
+					t.Fatal("Expected: idle connection; Got: timeout")
+				}
+			}
+
+			s.CloseIdleConnections()
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			if _, err := reader.ReadByte(); err != io.EOF {
+				t.Errorf("Expected: connection closed; Got: %v", err)
+			}
+		})
+}
+
+func TestStopShouldCloseTheHijackedConnectionsOnceTheDrainTimeoutExpires(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/ws").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+		rw.Flush()
+		// The handler ignores shutdown, blocking until its connection is closed.
+		io.Copy(ioutil.Discard, conn)
+	})
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = 200 * time.Millisecond
+	server := New(configs, router)
+	go server.Start()
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", configs.Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected: 101; Got: %d", resp.StatusCode)
+	}
+
+	start := time.Now()
+	stopped := make(chan error)
+	go func() {
+		stopped <- server.Stop()
+	}()
+	select {
+	case err := <-stopped:
+		if elapsed := time.Since(start); elapsed < configs.ShutdownTimeout {
+			t.Errorf("Expected: Stop to wait for the drain timeout; Got: returned after %s", elapsed)
+		}
+		if err != context.DeadlineExceeded {
+			t.Errorf("Expected: context.DeadlineExceeded; Got: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected: Stop to return at the drain timeout; Got: Stop hung")
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := reader.ReadByte(); err != io.EOF {
+		t.Errorf("Expected: hijacked connection closed; Got: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"context"
+	"net/http"
+)
+
+// contextValue holds a value injected in the context of every request.
+type contextValue struct {
+	key   interface{}
+	value interface{}
+}
+
+// WithContextValue injects value under key in the context of every request, such as to share a database handle with
+// the handlers. As with context.WithValue, key should be of an unexported type to avoid collisions. Values injected
+// later under the same key shadow the previous ones.
+func (s *ServerImpl) WithContextValue(key, value interface{}) {
+	s.contextValues = append(s.contextValues, contextValue{key: key, value: value})
+}
+
+// ValueFromContext returns the value stored under key in ctx, such as one injected with WithContextValue. ok is false
+// if ctx holds no value under key or the value is not a T.
+func ValueFromContext[T any](ctx context.Context, key interface{}) (value T, ok bool) {
+	value, ok = ctx.Value(key).(T)
+	return value, ok
+}
+
+// injectContextValues injects the values registered with WithContextValue in the request context.
+func (s *ServerImpl) injectContextValues(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.contextValues) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		for _, v := range s.contextValues {
+			ctx = context.WithValue(ctx, v.key, v.value)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
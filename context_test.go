@@ -0,0 +1,55 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestWithContextValueShouldInjectTheValueInEveryRequestContext(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/value").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, ok := ValueFromContext[string](r.Context(), testContextKey("injected"))
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if _, ok := ValueFromContext[int](r.Context(), testContextKey("injected")); ok {
+			t.Error("Expected: no int value under the key; Got: found")
+		}
+		w.Write([]byte(value))
+	})
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.WithContextValue(testContextKey("injected"), "injected")
+		},
+		func(s Server) {
+			status, body := getBody(t, configs.Port, "/value")
+			if status != 200 || body != "injected" {
+				t.Errorf("Expected: 200 injected; Got: %d %s", status, body)
+			}
+		})
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	// DefaultCORSAllowedMethods holds the methods allowed for cross-origin requests when CORSConfig.AllowedMethods is not set.
+	DefaultCORSAllowedMethods = []string{"GET", "HEAD", "POST"}
+)
+
+// CORSConfig holds the cross-origin resource sharing configs.
+// AllowedOrigins holds the origins allowed to make cross-origin requests. "*" allows all origins.
+// AllowedMethods holds the methods allowed for cross-origin requests. Defaults to DefaultCORSAllowedMethods.
+// AllowedHeaders holds the request headers allowed for cross-origin requests.
+// AllowCredentials indicates whether the response to the request can be exposed when credentials are included.
+// MaxAge holds how long the results of a preflight request can be cached. Zero omits the Access-Control-Max-Age header.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// CORSMiddleware returns a middleware enabling cross-origin resource sharing with c, such as for the routes registered
+// with HandleWithMiddleware when the CORS config is too broad. As route middlewares only run for the requests matching
+// the route methods, the route must match OPTIONS to answer the preflight requests.
+func (s *ServerImpl) CORSMiddleware(c *CORSConfig) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return s.corsHandler(c, next)
+	}
+}
+
+// cors enables cross-origin resource sharing for all routes with the CORS config.
+func (s *ServerImpl) cors(next http.Handler) http.Handler {
+	return s.corsHandler(s.Configs.CORS, next)
+}
+
+// corsHandler sets the Access-Control-* headers on the responses to the origins allowed by c and answers OPTIONS
+// requests with 204. OPTIONS responses list the methods of the routes matching the request path in the Allow header, so
+// both CORS preflight requests and non-CORS tooling are answered by the same handler.
+func (s *ServerImpl) corsHandler(c *CORSConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowedOrigin := origin != "" && c.isOriginAllowed(origin)
+		if origin != "" {
+			w.Header().Add("Vary", "Origin")
+		}
+		if allowedOrigin {
+			if c.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			} else if containsString(c.AllowedOrigins, "*") {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+		}
+
+		if r.Method != "OPTIONS" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		methods := routeMethods(s.currentRouter(), r)
+		preflight := allowedOrigin && r.Header.Get("Access-Control-Request-Method") != ""
+		if !preflight && (len(methods) == 0 || containsString(methods, "OPTIONS")) {
+			// Unknown paths and routes handling OPTIONS themselves are left to the router.
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(methods) > 0 {
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+		}
+		if preflight {
+			allowedMethods := c.AllowedMethods
+			if len(allowedMethods) == 0 {
+				allowedMethods = DefaultCORSAllowedMethods
+			}
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(allowedMethods, ", "))
+			if len(c.AllowedHeaders) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+			}
+			if c.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (c *CORSConfig) isOriginAllowed(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeMethods returns the methods of all routes in router matching the path of r, regardless of their methods.
+func routeMethods(router *mux.Router, r *http.Request) []string {
+	var methods []string
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		routeMethodList, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		var match mux.RouteMatch
+		if route.Match(r, &match) || match.MatchErr == mux.ErrMethodMismatch {
+			for _, method := range routeMethodList {
+				if !containsString(methods, method) {
+					methods = append(methods, method)
+				}
+			}
+		}
+		return nil
+	})
+	return methods
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	corsEndpoint  = "/cors"
+	allowedOrigin = "https://allowed.example.com"
+)
+
+func TestCORSShouldAnswerPreflightRequestsFromAllowedOrigins(t *testing.T) {
+	configs := getCORSTestConfigs()
+
+	runTestServer(t, configs, getCORSTestRouter(), true, nil, func(s Server) {
+		resp := doRequest(t, "OPTIONS", configs.Port, corsEndpoint, http.Header{
+			"Origin":                        {allowedOrigin},
+			"Access-Control-Request-Method": {"POST"},
+		})
+
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("Expected: 204; Got: %d", resp.StatusCode)
+		}
+		expectHeader(t, resp, "Access-Control-Allow-Origin", allowedOrigin)
+		expectHeader(t, resp, "Access-Control-Allow-Methods", "GET, POST")
+		expectHeader(t, resp, "Access-Control-Allow-Headers", "Content-Type, Authorization")
+		expectHeader(t, resp, "Access-Control-Allow-Credentials", "true")
+		expectHeader(t, resp, "Access-Control-Max-Age", "600")
+	})
+}
+
+func TestCORSPreflightShouldListTheMatchedRouteMethodsInTheAllowHeader(t *testing.T) {
+	configs := getCORSTestConfigs()
+	configs.CORS.AllowedMethods = []string{"GET", "POST", "PUT", "DELETE"}
+
+	runTestServer(t, configs, getCORSTestRouter(), true, nil, func(s Server) {
+		resp := doRequest(t, "OPTIONS", configs.Port, corsEndpoint, http.Header{
+			"Origin":                        {allowedOrigin},
+			"Access-Control-Request-Method": {"POST"},
+		})
+
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("Expected: 204; Got: %d", resp.StatusCode)
+		}
+		expectHeader(t, resp, "Allow", "GET, POST")
+		expectHeader(t, resp, "Access-Control-Allow-Origin", allowedOrigin)
+		expectHeader(t, resp, "Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
+	})
+}
+
+func TestCORSShouldAnswerNonCORSOptionsRequestsWithTheAllowHeader(t *testing.T) {
+	configs := getCORSTestConfigs()
+
+	runTestServer(t, configs, getCORSTestRouter(), true, nil, func(s Server) {
+		resp := doRequest(t, "OPTIONS", configs.Port, corsEndpoint, nil)
+
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("Expected: 204; Got: %d", resp.StatusCode)
+		}
+		expectHeader(t, resp, "Allow", "GET, POST")
+		expectHeader(t, resp, "Access-Control-Allow-Methods", "")
+	})
+}
+
+func TestCORSShouldSetHeadersOnSimpleRequestsFromAllowedOrigins(t *testing.T) {
+	configs := getCORSTestConfigs()
+
+	runTestServer(t, configs, getCORSTestRouter(), true, nil, func(s Server) {
+		resp := doRequest(t, "GET", configs.Port, corsEndpoint, http.Header{"Origin": {allowedOrigin}})
+
+		if resp.StatusCode != 200 {
+			t.Errorf("Expected: 200; Got: %d", resp.StatusCode)
+		}
+		expectHeader(t, resp, "Access-Control-Allow-Origin", allowedOrigin)
+		expectHeader(t, resp, "Access-Control-Allow-Credentials", "true")
+		expectHeader(t, resp, "Access-Control-Allow-Methods", "")
+	})
+}
+
+func TestCORSShouldNotSetHeadersOnRequestsFromOtherOrigins(t *testing.T) {
+	configs := getCORSTestConfigs()
+
+	runTestServer(t, configs, getCORSTestRouter(), true, nil, func(s Server) {
+		resp := doRequest(t, "GET", configs.Port, corsEndpoint, http.Header{"Origin": {"https://other.example.com"}})
+
+		if resp.StatusCode != 200 {
+			t.Errorf("Expected: 200; Got: %d", resp.StatusCode)
+		}
+		expectHeader(t, resp, "Access-Control-Allow-Origin", "")
+	})
+}
+
+func getCORSTestConfigs() *Configs {
+	configs := getTestConfigs()
+	configs.CORS = &CORSConfig{
+		AllowedOrigins:   []string{allowedOrigin},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	return configs
+}
+
+func getCORSTestRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.Path(corsEndpoint).Methods("GET", "POST").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	})
+	return router
+}
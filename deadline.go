@@ -0,0 +1,153 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRequestTimeoutHeader holds the default request header clients send their timeout in, in milliseconds.
+	DefaultRequestTimeoutHeader = "X-Request-Timeout-Ms"
+
+	// DefaultRequestTimeoutMax holds the default maximum timeout accepted from clients.
+	DefaultRequestTimeoutMax = 30 * time.Second
+)
+
+// RequestTimeoutConfig holds the configs of the timeouts requested by clients through a request header.
+// Header holds the request header holding the timeout in milliseconds. Defaults to DefaultRequestTimeoutHeader.
+// Max holds the maximum timeout accepted. Longer timeouts are capped to it. Defaults to DefaultRequestTimeoutMax.
+type RequestTimeoutConfig struct {
+	Header string
+	Max    time.Duration
+}
+
+// requestTimeout applies the timeout requested in the request timeout header as a deadline on the request context,
+// replying 504 if the handler doesn't finish by then. Requests without a valid timeout, to the shutdown endpoint and streaming endpoints are served as is.
+func (s *ServerImpl) requestTimeout(next http.Handler) http.Handler {
+	header := s.Configs.RequestTimeout.Header
+	if header == "" {
+		header = DefaultRequestTimeoutHeader
+	}
+	max := s.Configs.RequestTimeout.Max
+	if max <= 0 {
+		max = DefaultRequestTimeoutMax
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ms, err := strconv.ParseInt(r.Header.Get(header), 10, 64)
+		if err != nil || ms <= 0 || s.timeoutExemptPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		timeout := max
+		if ms < int64(max/time.Millisecond) {
+			timeout = time.Duration(ms) * time.Millisecond
+		}
+		serveWithTimeout(w, r, next, timeout, func() {
+			s.writeError(w, http.StatusGatewayTimeout)
+		})
+	})
+}
+
+// serveWithTimeout serves r with next, with timeout as a deadline on the request context, calling timedOut to reply if
+// next doesn't finish by then. As with http.TimeoutHandler, next keeps running in its own goroutine until it returns,
+// but its writes after the deadline fail with http.ErrHandlerTimeout. r stays uninterruptible, if marked so, until next returns.
+func serveWithTimeout(w http.ResponseWriter, r *http.Request, next http.Handler, timeout time.Duration, timedOut func()) {
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	dw := &deadlineWriter{header: make(http.Header), status: http.StatusOK}
+	done := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+	release := holdRequest(r)
+	go func() {
+		defer release()
+		defer func() {
+			if p := recover(); p != nil {
+				panicked <- p
+			}
+		}()
+		next.ServeHTTP(dw, r.WithContext(ctx))
+		close(done)
+	}()
+
+	select {
+	case p := <-panicked:
+		panic(p)
+	case <-done:
+		dw.mu.Lock()
+		defer dw.mu.Unlock()
+		for key, values := range dw.header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(dw.status)
+		w.Write(dw.buf.Bytes())
+	case <-ctx.Done():
+		dw.mu.Lock()
+		defer dw.mu.Unlock()
+		dw.timedOut = true
+		if ctx.Err() == context.DeadlineExceeded {
+			timedOut()
+		}
+	}
+}
+
+// deadlineWriter buffers the response of a handler running with a deadline, discarding the writes after it expires.
+type deadlineWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	status      int
+	buf         bytes.Buffer
+	wroteHeader bool
+	timedOut    bool
+}
+
+// Header returns the headers sent along with the buffered response.
+func (w *deadlineWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader records the status code sent along with the buffered response.
+func (w *deadlineWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.timedOut && !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+}
+
+// Write buffers b, failing with http.ErrHandlerTimeout once the deadline expired.
+func (w *deadlineWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.wroteHeader = true
+	return w.buf.Write(b)
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRequestTimeoutShouldReply504WhenTheHandlerExceedsTheRequestedTimeout(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path(slowEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	router.Path("/fast").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("Expected: a request context deadline; Got: none")
+		}
+		w.Header().Set("X-Served", "fast")
+		w.WriteHeader(http.StatusCreated)
+	})
+	configs := getTestConfigs()
+	configs.RequestTimeout = &RequestTimeoutConfig{}
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		timeout := http.Header{DefaultRequestTimeoutHeader: []string{"20"}}
+		if resp := doRequest(t, "GET", configs.Port, slowEndpoint, timeout); resp.StatusCode != http.StatusGatewayTimeout {
+			t.Errorf("Expected: 504; Got: %d", resp.StatusCode)
+		}
+		resp := doRequest(t, "GET", configs.Port, "/fast", timeout)
+		if resp.StatusCode != http.StatusCreated {
+			t.Errorf("Expected: 201; Got: %d", resp.StatusCode)
+		}
+		expectHeader(t, resp, "X-Served", "fast")
+		testEndpoint(t, configs.Port, slowEndpoint, 200)
+	})
+}
+
+func TestRequestTimeoutShouldCapTheRequestedTimeoutToTheMax(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path(slowEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	configs := getTestConfigs()
+	configs.RequestTimeout = &RequestTimeoutConfig{Header: "X-Timeout", Max: 20 * time.Millisecond}
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		timeout := http.Header{"X-Timeout": []string{"60000"}}
+		if resp := doRequest(t, "GET", configs.Port, slowEndpoint, timeout); resp.StatusCode != http.StatusGatewayTimeout {
+			t.Errorf("Expected: 504; Got: %d", resp.StatusCode)
+		}
+	})
+}
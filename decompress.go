@@ -0,0 +1,82 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// gzipBody wraps a gzip-decompressed request body, recording whether the compressed stream is malformed.
+type gzipBody struct {
+	*gzip.Reader
+	body      io.ReadCloser
+	malformed bool
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	var corrupt flate.CorruptInputError
+	if errors.Is(err, gzip.ErrChecksum) || errors.Is(err, gzip.ErrHeader) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &corrupt) {
+		b.malformed = true
+	}
+	return n, err
+}
+
+// Close closes the decompressor and the compressed request body.
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
+
+// decompressRequests transparently decompresses the request bodies sent with a gzip Content-Encoding, replying 400 to
+// the malformed ones unless the handler already wrote a response. As the decompressed size is unknown, the request
+// Content-Length is reset to -1 and the Content-Encoding and Content-Length headers are removed.
+func (s *ServerImpl) decompressRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		if encoding != "gzip" && encoding != "x-gzip" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			s.writeErrorDetail(w, http.StatusBadRequest, "malformed gzip request body")
+			return
+		}
+		body := &gzipBody{Reader: reader, body: r.Body}
+		r.Body = body
+		r.ContentLength = -1
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+
+		rw := newResponseWriter(w)
+		next.ServeHTTP(rw, r)
+		if body.malformed && !rw.wroteHeader {
+			s.writeErrorDetail(w, http.StatusBadRequest, "malformed gzip request body")
+		}
+	})
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	decompressEndpoint = "/decompress"
+)
+
+func TestDecompressRequestsShouldDecompressGzipRequestBodies(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path(decompressEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+		if r.Header.Get("Content-Encoding") != "" {
+			t.Errorf("Expected: no Content-Encoding; Got: %s", r.Header.Get("Content-Encoding"))
+		}
+		w.Header().Set("X-Content-Length", fmt.Sprint(r.ContentLength))
+		w.Write(body)
+	})
+	configs := getTestConfigs()
+	configs.DecompressRequests = true
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		zw.Write([]byte("hello gzip"))
+		zw.Close()
+
+		status, body, length := postEncoded(t, configs.Port, compressed.Bytes())
+		if status != 200 || body != "hello gzip" || length != "-1" {
+			t.Errorf("Expected: 200 hello gzip of unknown length; Got: %d %s of length %s", status, body, length)
+		}
+		if status, _, _ := postEncoded(t, configs.Port, []byte("not gzip")); status != http.StatusBadRequest {
+			t.Errorf("Expected: 400; Got: %d", status)
+		}
+		truncated := compressed.Bytes()[:compressed.Len()-4]
+		if status, _, _ := postEncoded(t, configs.Port, truncated); status != http.StatusBadRequest {
+			t.Errorf("Expected: 400 on truncated body; Got: %d", status)
+		}
+		expectPostStatus(t, configs.Port, decompressEndpoint, strings.NewReader("plain"), 200)
+	})
+}
+
+// postEncoded posts the gzip encoded body to the decompress endpoint, returning the status code, the response body and
+// the request Content-Length seen by the handler.
+func postEncoded(t *testing.T, port int, body []byte) (int, string, string) {
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s:%d%s", testServerEndpoint, port, decompressEndpoint), bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(respBody), resp.Header.Get("X-Content-Length")
+}
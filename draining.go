@@ -0,0 +1,49 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// RegisterRejectedRequestHandler registers a function called with each request rejected while the server drains, such
+// as to alert on dropped traffic. Once registered, the requests received after shutdown begins, but the ones to the
+// built-in endpoints, are replied with 503 and their connection is closed instead of serving them. This includes the
+// requests received during the PreShutdownDelay, which load balancers still send until they notice the readiness
+// endpoint replying 503; afterwards the HTTP server stops reading new requests altogether.
+func (s *ServerImpl) RegisterRejectedRequestHandler(f func(r *http.Request)) {
+	s.rejectedRequestHandler = f
+}
+
+// rejectWhileDraining replies 503 to the requests received after shutdown begins when a rejected request handler is
+// registered, calling it with the request.
+func (s *ServerImpl) rejectWhileDraining(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.rejectedRequestHandler == nil || atomic.LoadInt32(&s.notReady) == 0 || s.isBuiltinEndpoint(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.rejectedRequestHandler(r)
+		w.Header().Set("Connection", "close")
+		s.writeError(w, http.StatusServiceUnavailable)
+	})
+}
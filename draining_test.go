@@ -0,0 +1,86 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRequestReceivedWhileDrainingShouldBeRejectedWith503(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/items").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	configs := getTestConfigs()
+	configs.PreShutdownDelay = 200 * time.Millisecond
+	server := New(configs, router)
+	rejected := make(chan string, 1)
+	server.RegisterRejectedRequestHandler(func(r *http.Request) {
+		rejected <- r.URL.Path
+	})
+
+	go server.Start()
+	testEndpoint(t, configs.Port, "/items", 200)
+	stopped := make(chan error)
+	go func() {
+		stopped <- server.Stop()
+	}()
+	for attempt := 1; doRequest(t, "GET", configs.Port, DefaultReadinessEndpoint, nil).StatusCode != 503; attempt++ {
+		if attempt == maxRetries {
+			t.Fatal("Expected: shutdown to begin; Got: readiness endpoint still ready")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resp := doRequest(t, "GET", configs.Port, "/items", nil)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected: 503; Got: %d", resp.StatusCode)
+	}
+	select {
+	case path := <-rejected:
+		if path != "/items" {
+			t.Errorf("Expected: /items rejected; Got: %s", path)
+		}
+	default:
+		t.Error("Expected: rejected request handler called; Got: not called")
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("Expected: success; Got: %v", err)
+	}
+}
+
+func TestRequestReceivedBeforeShutdownShouldNotBeRejected(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/items").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterRejectedRequestHandler(func(r *http.Request) {
+				t.Error("Expected: request served; Got: rejected")
+			})
+		},
+		func(s Server) {
+			testEndpoint(t, configs.Port, "/items", 200)
+		})
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestEchoEndpointShouldReplyTheRequestDetails(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.EnableEchoEndpoint = true
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		req, err := http.NewRequest("POST", fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, DefaultEchoEndpoint), strings.NewReader("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Test", "value")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected: 200; Got: %d", resp.StatusCode)
+		}
+		echo := echoResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&echo); err != nil {
+			t.Fatal(err)
+		}
+		if echo.Method != "POST" {
+			t.Errorf("Expected: POST; Got: %s", echo.Method)
+		}
+		if echo.Headers.Get("X-Test") != "value" {
+			t.Errorf("Expected: value; Got: %s", echo.Headers.Get("X-Test"))
+		}
+		if echo.Body != "hello" {
+			t.Errorf("Expected: hello; Got: %s", echo.Body)
+		}
+	})
+}
+
+func TestEchoEndpointShouldRejectBodiesLargerThanTheMaxSize(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.EnableEchoEndpoint = true
+	configs.EchoMaxBodySize = 4
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		resp, err := http.Post(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, DefaultEchoEndpoint), "text/plain", strings.NewReader("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected: 413; Got: %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestEchoEndpointShouldNotBeRegisteredByDefault(t *testing.T) {
+	router := mux.NewRouter()
+	New(getTestConfigs(), router)
+
+	if router.GetRoute(DefaultEchoEndpoint) != nil {
+		t.Error("Expected: echo endpoint not configured; Got: configured")
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RegisterExpectContinueHandler registers a function deciding whether to accept the requests sent with an
+// Expect: 100-continue header, such as based on their Content-Length. Rejected requests are replied with 417 before
+// the client sends the body. The HTTP server sends the 100 Continue response once the handler first reads the body.
+func (s *ServerImpl) RegisterExpectContinueHandler(f func(r *http.Request) bool) {
+	s.expectContinueHandler = f
+}
+
+// expectContinue replies 417 to the requests expecting 100-continue rejected by the expect continue handler.
+func (s *ServerImpl) expectContinue(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.expectContinueHandler == nil || !expectsContinue(r) || s.expectContinueHandler(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.writeError(w, http.StatusExpectationFailed)
+	})
+}
+
+// expectsContinue returns whether r was sent with an Expect: 100-continue header.
+func expectsContinue(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Expect"), "100-continue")
+}
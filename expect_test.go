@@ -0,0 +1,92 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	uploadEndpoint = "/upload"
+)
+
+func TestOversizedRequestExpectingContinueShouldBeRepliedWith417WithoutReadingTheBody(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path(uploadEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected: the handler not to be called; Got: called")
+	})
+	configs := getTestConfigs()
+	configs.MaxRequestBodyBytes = 10
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		if status := sendExpectContinue(t, configs.Port, uploadEndpoint, 1000); status != http.StatusExpectationFailed {
+			t.Errorf("Expected: 417; Got: %d", status)
+		}
+		expectPostStatus(t, configs.Port, uploadEndpoint, strings.NewReader(strings.Repeat("a", 1000)), http.StatusRequestEntityTooLarge)
+	})
+}
+
+func TestExpectContinueHandlerShouldDecideWhetherToAcceptTheBody(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path(uploadEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterExpectContinueHandler(func(r *http.Request) bool {
+				return r.ContentLength <= 100
+			})
+		},
+		func(s Server) {
+			if status := sendExpectContinue(t, configs.Port, uploadEndpoint, 1000); status != http.StatusExpectationFailed {
+				t.Errorf("Expected: 417; Got: %d", status)
+			}
+			expectPostStatus(t, configs.Port, uploadEndpoint, strings.NewReader(strings.Repeat("a", 1000)), http.StatusNoContent)
+		})
+}
+
+// sendExpectContinue sends the headers of a POST request to path expecting 100-continue and declaring contentLength,
+// returning the status code replied without sending the body.
+func sendExpectContinue(t *testing.T, port int, path string, contentLength int) int {
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	fmt.Fprintf(conn, "POST %s HTTP/1.1\r\nHost: localhost\r\nExpect: 100-continue\r\nContent-Length: %d\r\n\r\n", path, contentLength)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
@@ -1,5 +1,14 @@
 module github.com/cloud-spin/server
 
+go 1.20
+
+require (
+	github.com/gorilla/mux v1.6.2
+	golang.org/x/crypto v0.31.0
+)
+
 require (
-    github.com/gorilla/mux v1.6.2
-)
\ No newline at end of file
+	github.com/gorilla/context v1.1.2 // indirect
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
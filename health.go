@@ -0,0 +1,174 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthCriticality represents how a failing health check affects the healthcheck endpoint response.
+type HealthCriticality int
+
+const (
+	// Critical health checks failing make the healthcheck endpoint reply 503.
+	Critical HealthCriticality = iota
+
+	// Optional health checks failing make the healthcheck endpoint reply 200, flagging the server as degraded.
+	Optional
+)
+
+// healthChecker holds a health check registered with RegisterHealthChecker.
+type healthChecker struct {
+	name        string
+	criticality HealthCriticality
+	check       func(ctx context.Context) error
+}
+
+// healthResponse holds the body replied by the healthcheck endpoint when health checkers are registered.
+type healthResponse struct {
+	Status   string                       `json:"status"`
+	Degraded bool                         `json:"degraded"`
+	Checks   map[string]healthCheckResult `json:"checks"`
+}
+
+// healthCheckResult holds the result of a single health check.
+type healthCheckResult struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+}
+
+// healthCache caches the results of the health checkers for the HealthCheckCacheTTL, running them once at a time.
+type healthCache struct {
+	ttl        time.Duration
+	mu         sync.Mutex
+	expires    time.Time
+	status     int
+	response   healthResponse
+	refreshing chan struct{}
+}
+
+// get returns the cached results, running check to refresh them once expired. Concurrent calls during a refresh wait
+// for it instead of running check again. A panicking check results in an unhealthy response.
+func (c *healthCache) get(check func() (int, healthResponse)) (status int, response healthResponse) {
+	c.mu.Lock()
+	for {
+		if time.Now().Before(c.expires) {
+			defer c.mu.Unlock()
+			return c.status, c.response
+		}
+		if c.refreshing == nil {
+			break
+		}
+		refreshing := c.refreshing
+		c.mu.Unlock()
+		<-refreshing
+		c.mu.Lock()
+	}
+	refreshing := make(chan struct{})
+	c.refreshing = refreshing
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.status, c.response = status, response
+		c.expires = time.Now().Add(c.ttl)
+		c.refreshing = nil
+		c.mu.Unlock()
+		close(refreshing)
+	}()
+	defer func() {
+		if p := recover(); p != nil {
+			status = http.StatusServiceUnavailable
+			response = healthResponse{Status: "unhealthy", Checks: map[string]healthCheckResult{}}
+		}
+	}()
+	return check()
+}
+
+// RegisterHealthChecker registers check to run on each request to the healthcheck endpoint, which replies the results
+// of all checks as JSON. The endpoint replies 503 if a Critical check fails, or 200 flagged as degraded if only
+// Optional checks fail. Checks receive the request context bounded by the HealthCheckTimeout, so a client going away
+// cancels the checks in progress and skips the remaining ones. When the results are cached for the HealthCheckCacheTTL,
+// checks receive a background context bounded by the HealthCheckTimeout instead, so a client going away doesn't fail the
+// results shared with the other clients. A handler registered with RegisterHealthcheckEndpoint takes precedence over the checkers.
+func (s *ServerImpl) RegisterHealthChecker(name string, criticality HealthCriticality, check func(ctx context.Context) error) {
+	s.healthCheckers = append(s.healthCheckers, healthChecker{name: name, criticality: criticality, check: check})
+}
+
+// handleFuncHealthChecks runs all health checkers, or reuses their cached results, replying them.
+func (s *ServerImpl) handleFuncHealthChecks(w http.ResponseWriter, r *http.Request) {
+	var status int
+	var response healthResponse
+	if s.healthCache != nil {
+		status, response = s.healthCache.get(func() (int, healthResponse) {
+			return s.runHealthChecks(context.Background())
+		})
+	} else {
+		status, response = s.runHealthChecks(r.Context())
+	}
+	if r.Context().Err() != nil {
+		// The client went away, so there is nobody to reply to.
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// runHealthChecks runs all health checkers with ctx bounded by the HealthCheckTimeout, returning the status code and the
+// body to reply. Once ctx is done, the remaining checkers fail with its error without running.
+func (s *ServerImpl) runHealthChecks(ctx context.Context) (int, healthResponse) {
+	if s.Configs.HealthCheckTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Configs.HealthCheckTimeout)
+		defer cancel()
+	}
+	response := healthResponse{Status: "ok", Checks: make(map[string]healthCheckResult, len(s.healthCheckers))}
+	status := http.StatusOK
+	for _, checker := range s.healthCheckers {
+		result := healthCheckResult{Status: "ok", Critical: checker.criticality == Critical}
+		err := ctx.Err()
+		if err == nil {
+			err = checker.check(ctx)
+		}
+		if err != nil {
+			result.Status = "failed"
+			result.Error = err.Error()
+			if result.Critical {
+				status = http.StatusServiceUnavailable
+				response.Status = "unhealthy"
+			} else {
+				response.Degraded = true
+			}
+		}
+		response.Checks[checker.name] = result
+	}
+	if status == http.StatusOK && response.Degraded {
+		response.Status = "degraded"
+	}
+	return status, response
+}
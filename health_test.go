@@ -0,0 +1,252 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestHealthcheckWithFailingCriticalCheckShouldReply503(t *testing.T) {
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, mux.NewRouter(), true,
+		func(s Server) {
+			s.RegisterHealthChecker("database", Critical, func(ctx context.Context) error {
+				return errors.New("connection refused")
+			})
+			s.RegisterHealthChecker("cache", Optional, func(ctx context.Context) error {
+				return nil
+			})
+		},
+		func(s Server) {
+			status, body := getHealthResponse(t, configs.Port)
+			if status != http.StatusServiceUnavailable {
+				t.Errorf("Expected: 503; Got: %d", status)
+			}
+			if body.Status != "unhealthy" {
+				t.Errorf("Expected: unhealthy; Got: %s", body.Status)
+			}
+			if check := body.Checks["database"]; check.Status != "failed" || !check.Critical || check.Error != "connection refused" {
+				t.Errorf("Expected: critical database check failed; Got: %+v", check)
+			}
+			if check := body.Checks["cache"]; check.Status != "ok" || check.Critical {
+				t.Errorf("Expected: optional cache check ok; Got: %+v", check)
+			}
+		})
+}
+
+func TestHealthcheckWithOnlyFailingOptionalChecksShouldReply200Degraded(t *testing.T) {
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, mux.NewRouter(), true,
+		func(s Server) {
+			s.RegisterHealthChecker("database", Critical, func(ctx context.Context) error {
+				return nil
+			})
+			s.RegisterHealthChecker("cache", Optional, func(ctx context.Context) error {
+				return errors.New("timeout")
+			})
+		},
+		func(s Server) {
+			status, body := getHealthResponse(t, configs.Port)
+			if status != http.StatusOK {
+				t.Errorf("Expected: 200; Got: %d", status)
+			}
+			if body.Status != "degraded" || !body.Degraded {
+				t.Errorf("Expected: degraded; Got: %+v", body)
+			}
+		})
+}
+
+func TestHealthcheckWithPassingChecksShouldReply200(t *testing.T) {
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, mux.NewRouter(), true,
+		func(s Server) {
+			s.RegisterHealthChecker("database", Critical, func(ctx context.Context) error {
+				return nil
+			})
+		},
+		func(s Server) {
+			status, body := getHealthResponse(t, configs.Port)
+			if status != http.StatusOK || body.Status != "ok" || body.Degraded {
+				t.Errorf("Expected: 200 ok; Got: %d %+v", status, body)
+			}
+		})
+}
+
+func TestHealthcheckWithCacheTTLShouldRunTheCheckersOnceWithinTheTTL(t *testing.T) {
+	configs := getTestConfigs()
+	configs.HealthCheckCacheTTL = time.Minute
+	var runs int32
+
+	runTestServer(t, configs, mux.NewRouter(), true,
+		func(s Server) {
+			s.RegisterHealthChecker("database", Critical, func(ctx context.Context) error {
+				atomic.AddInt32(&runs, 1)
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			})
+		},
+		func(s Server) {
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if status, body := getHealthResponse(t, configs.Port); status != 200 || body.Checks["database"].Status != "ok" {
+						t.Errorf("Expected: 200 with the database check ok; Got: %d %+v", status, body)
+					}
+				}()
+			}
+			wg.Wait()
+			getHealthResponse(t, configs.Port)
+
+			if got := atomic.LoadInt32(&runs); got != 1 {
+				t.Errorf("Expected: the checker to run once; Got: %d", got)
+			}
+		})
+}
+
+func TestHealthcheckWithCacheTTLShouldReplyUnhealthyAndReleaseTheWaitersWhenACheckerPanics(t *testing.T) {
+	configs := getTestConfigs()
+	configs.HealthCheckCacheTTL = 20 * time.Millisecond
+	var runs int32
+
+	runTestServer(t, configs, mux.NewRouter(), true,
+		func(s Server) {
+			s.RegisterHealthChecker("database", Critical, func(ctx context.Context) error {
+				if atomic.AddInt32(&runs, 1) == 1 {
+					panic("database driver panic")
+				}
+				return nil
+			})
+		},
+		func(s Server) {
+			if status, body := getHealthResponse(t, configs.Port); status != 503 || body.Status != "unhealthy" {
+				t.Errorf("Expected: 503 unhealthy; Got: %d %+v", status, body)
+			}
+			time.Sleep(2 * configs.HealthCheckCacheTTL)
+
+			statuses := make(chan int, 1)
+			go func() {
+				status, _ := getHealthResponse(t, configs.Port)
+				statuses <- status
+			}()
+			select {
+			case status := <-statuses:
+				if status != 200 {
+					t.Errorf("Expected: 200 once refreshed; Got: %d", status)
+				}
+			case <-time.After(time.Second):
+				t.Error("Expected: the cache refreshed after the panic; Got: request waiting for the panicked refresh")
+			}
+		})
+}
+
+func TestHealthcheckShouldCancelTheChecksWhenTheClientGoesAway(t *testing.T) {
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+	started := make(chan struct{})
+	observed := make(chan error, 1)
+	var remainingRan int32
+
+	runTestServer(t, configs, mux.NewRouter(), true,
+		func(s Server) {
+			s.RegisterHealthChecker("database", Critical, func(ctx context.Context) error {
+				close(started)
+				select {
+				case <-ctx.Done():
+					observed <- ctx.Err()
+				case <-time.After(5 * time.Second):
+					observed <- nil
+				}
+				return ctx.Err()
+			})
+			s.RegisterHealthChecker("cache", Optional, func(ctx context.Context) error {
+				atomic.AddInt32(&remainingRan, 1)
+				return nil
+			})
+		},
+		func(s Server) {
+			ctx, cancel := context.WithCancel(context.Background())
+			req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, DefaultHealthcheckEndpoint), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			go func() {
+				<-started
+				cancel()
+			}()
+			if _, err := http.DefaultClient.Do(req); !errors.Is(err, context.Canceled) {
+				t.Errorf("Expected: %v; Got: %v", context.Canceled, err)
+			}
+
+			if err := <-observed; !errors.Is(err, context.Canceled) {
+				t.Errorf("Expected: the checker to observe %v; Got: %v", context.Canceled, err)
+			}
+			if got := atomic.LoadInt32(&remainingRan); got != 0 {
+				t.Errorf("Expected: the remaining checker skipped; Got: %d runs", got)
+			}
+		})
+}
+
+func TestHealthcheckWithTimeoutShouldFailTheChecksRunningPastIt(t *testing.T) {
+	configs := getTestConfigs()
+	configs.HealthCheckTimeout = 50 * time.Millisecond
+
+	runTestServer(t, configs, mux.NewRouter(), true,
+		func(s Server) {
+			s.RegisterHealthChecker("database", Critical, func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			})
+		},
+		func(s Server) {
+			status, body := getHealthResponse(t, configs.Port)
+			if status != http.StatusServiceUnavailable || body.Checks["database"].Error != context.DeadlineExceeded.Error() {
+				t.Errorf("Expected: 503 with the database check timed out; Got: %d %+v", status, body)
+			}
+		})
+}
+
+func getHealthResponse(t *testing.T, port int) (int, healthResponse) {
+	resp, err := http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, port, DefaultHealthcheckEndpoint))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body := healthResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, body
+}
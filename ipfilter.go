@@ -0,0 +1,185 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ipFilter allows or denies clients based on their IP address.
+type ipFilter struct {
+	allowed        []*net.IPNet
+	denied         []*net.IPNet
+	trustedProxies []*net.IPNet
+	problemJSON    bool
+}
+
+// newIPFilter returns a filter for the configured CIDRs, or nil if no CIDRs were configured.
+func newIPFilter(configs *Configs) (*ipFilter, error) {
+	if len(configs.AllowedCIDRs) == 0 && len(configs.DeniedCIDRs) == 0 {
+		return nil, nil
+	}
+	allowed, err := parseCIDRs(configs.AllowedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	denied, err := parseCIDRs(configs.DeniedCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	trustedProxies, err := parseCIDRs(configs.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return &ipFilter{
+		allowed:        allowed,
+		denied:         denied,
+		trustedProxies: trustedProxies,
+		problemJSON:    configs.ProblemJSON,
+	}, nil
+}
+
+// parseCIDRs parses CIDR ranges, accepting single IP addresses as ranges holding only that address.
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address: %s", cidr)
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows returns whether ip is not denied and, when allowed CIDRs are configured, is allowed.
+func (f *ipFilter) allows(ip net.IP) bool {
+	if ip == nil || containsIP(f.denied, ip) {
+		return false
+	}
+	return len(f.allowed) == 0 || containsIP(f.allowed, ip)
+}
+
+// clientIP returns the IP address of the client that sent r, honoring the headers of the filter trusted proxies.
+func (f *ipFilter) clientIP(r *http.Request) net.IP {
+	return resolveClientIP(r, f.trustedProxies)
+}
+
+// ClientIP returns the IP address of the client that sent r. The X-Forwarded-For and X-Real-IP headers are only honored
+// when the request comes from one of the TrustedProxies; otherwise, or for requests not served by a Server, the
+// RemoteAddr IP is returned. An empty string is returned if no IP address can be resolved.
+func ClientIP(r *http.Request) string {
+	var trustedProxies []*net.IPNet
+	if state, ok := r.Context().Value(requestStateKey).(*requestState); ok {
+		trustedProxies = state.server.trustedProxies
+	}
+	if ip := resolveClientIP(r, trustedProxies); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// resolveClientIP returns the IP address of the client that sent r. The X-Forwarded-For and X-Real-IP headers are only
+// honored when the request comes from a trusted proxy. X-Real-IP is only read without X-Forwarded-For, and a hop that
+// cannot be parsed resolves to the peer, as whatever preceded it cannot be trusted.
+func resolveClientIP(r *http.Request, trustedProxies []*net.IPNet) net.IP {
+	peer := hostIP(r.RemoteAddr)
+	if peer == nil || !containsIP(trustedProxies, peer) {
+		return peer
+	}
+
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				return peer
+			}
+			if i == 0 || !containsIP(trustedProxies, ip) {
+				return ip
+			}
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip
+	}
+	return peer
+}
+
+// middleware replies 403 to the requests sent by clients that are not allowed.
+func (f *ipFilter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.allows(f.clientIP(r)) {
+			writeError(w, http.StatusForbidden, f.problemJSON)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// filteredListener closes the accepted connections from clients that are not allowed, before any HTTP parsing.
+type filteredListener struct {
+	net.Listener
+	filter *ipFilter
+}
+
+// Accept waits for and returns the next connection from an allowed client.
+func (l *filteredListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.filter.allows(hostIP(conn.RemoteAddr().String())) {
+			return conn, nil
+		}
+		conn.Close()
+	}
+}
+
+// hostIP returns the IP address of a host:port address.
+func hostIP(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return net.ParseIP(host)
+}
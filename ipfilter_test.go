@@ -0,0 +1,155 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServerWithAllowedCIDRsShouldAcceptConnectionsFromAllowedAddresses(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.AllowedCIDRs = []string{"127.0.0.0/8", "::1"}
+
+	runTestServer(t, configs, router, true, nil, nil)
+}
+
+func TestServerWithDeniedCIDRsShouldRejectConnectionsFromDeniedAddresses(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.AllowedCIDRs = []string{"10.0.0.0/8"}
+	configs.DeniedCIDRs = []string{"127.0.0.1/32"}
+	// The built-in endpoints on the admin port aren't filtered, telling when the server is ready.
+	configs.AdminPort = getTestConfigs().Port
+	server := New(configs, router)
+
+	go server.Start()
+	testEndpoint(t, configs.AdminPort, DefaultPingEndpoint, 200)
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+
+	if _, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", configs.Port, DefaultPingEndpoint)); err == nil {
+		t.Error("Expected: connection rejected; Got: success")
+	}
+}
+
+func TestServerWithTrustedProxiesShouldFilterForwardedClientAddresses(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.DeniedCIDRs = []string{"10.0.0.0/8"}
+	configs.TrustedProxies = []string{"127.0.0.1", "::1"}
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		if resp := doRequest(t, "GET", configs.Port, DefaultPingEndpoint, http.Header{"X-Forwarded-For": {"10.1.2.3"}}); resp.StatusCode != 403 {
+			t.Errorf("Expected: 403; Got: %d", resp.StatusCode)
+		}
+		if resp := doRequest(t, "GET", configs.Port, DefaultPingEndpoint, http.Header{"X-Forwarded-For": {"10.1.2.3, 192.168.1.1"}}); resp.StatusCode != 200 {
+			t.Errorf("Expected: 200; Got: %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestClientIPWithTrustedPeerShouldHonorForwardedHeaders(t *testing.T) {
+	configs := getTestConfigs()
+	configs.TrustedProxies = []string{"127.0.0.1", "::1"}
+
+	runTestServer(t, configs, newClientIPRouter(), true, nil, func(s Server) {
+		expectClientIP(t, configs.Port, http.Header{"X-Forwarded-For": {"192.168.1.1, 10.1.2.3"}}, "10.1.2.3")
+		expectClientIP(t, configs.Port, http.Header{"X-Real-IP": {"10.1.2.3"}}, "10.1.2.3")
+		expectClientIP(t, configs.Port, nil, "127.0.0.1")
+	})
+}
+
+func TestClientIPWithUnparsableForwardedHopShouldIgnoreTheRealIPHeader(t *testing.T) {
+	configs := getTestConfigs()
+	configs.TrustedProxies = []string{"127.0.0.1", "::1"}
+
+	runTestServer(t, configs, newClientIPRouter(), true, nil, func(s Server) {
+		header := http.Header{"X-Forwarded-For": {"unknown"}, "X-Real-IP": {"10.1.2.3"}}
+		expectClientIP(t, configs.Port, header, "127.0.0.1")
+	})
+}
+
+func TestClientIPWithUntrustedPeerShouldIgnoreSpoofedHeaders(t *testing.T) {
+	configs := getTestConfigs()
+	configs.TrustedProxies = []string{"10.0.0.0/8"}
+
+	runTestServer(t, configs, newClientIPRouter(), true, nil, func(s Server) {
+		expectClientIP(t, configs.Port, http.Header{"X-Forwarded-For": {"10.1.2.3"}}, "127.0.0.1")
+		expectClientIP(t, configs.Port, http.Header{"X-Real-IP": {"10.1.2.3"}}, "127.0.0.1")
+	})
+}
+
+func TestServerWithInvalidTrustedProxyShouldReturnErrorOnStart(t *testing.T) {
+	configs := getTestConfigs()
+	configs.TrustedProxies = []string{"invalid"}
+	server := New(configs, mux.NewRouter())
+
+	if err := server.Start(); err == nil {
+		t.Error("Expected: error as the trusted proxy is not valid; Got: success")
+	}
+}
+
+// newClientIPRouter returns a router replying the ClientIP of the requests in the X-Client-IP header.
+func newClientIPRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.Path("/client-ip").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Client-IP", ClientIP(r))
+	})
+	return router
+}
+
+func expectClientIP(t *testing.T, port int, header http.Header, expected string) {
+	t.Helper()
+	resp := doRequest(t, "GET", port, "/client-ip", header)
+	if ip := resp.Header.Get("X-Client-IP"); ip != expected {
+		t.Errorf("Expected: client IP %s; Got: %s", expected, ip)
+	}
+}
+
+func TestServerWithCustomStartHandlerShouldRejectDeniedAddressesWith403(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.DeniedCIDRs = []string{"127.0.0.1", "::1"}
+	server := New(configs, router)
+	server.RegisterServerStartHandler(func(s *http.Server) error {
+		return s.ListenAndServe()
+	})
+
+	go server.Start()
+	defer server.Stop()
+
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 403)
+}
+
+func TestServerWithInvalidCIDRShouldReturnErrorOnStart(t *testing.T) {
+	configs := getTestConfigs()
+	configs.AllowedCIDRs = []string{"invalid"}
+	server := New(configs, mux.NewRouter())
+
+	if err := server.Start(); err == nil {
+		t.Error("Expected: error as the CIDR is not valid; Got: success")
+	}
+}
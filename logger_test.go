@@ -0,0 +1,68 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// chanLogger sends each logged line to the channel.
+type chanLogger chan string
+
+func (l chanLogger) Printf(format string, args ...interface{}) {
+	l <- fmt.Sprintf(format, args...)
+}
+
+func TestServerWithLoggerShouldLogTheHTTPServerErrorsThroughIt(t *testing.T) {
+	dir := newTempDir(t)
+	defer os.RemoveAll(dir)
+	logs := make(chanLogger, 10)
+	configs := getTestConfigs()
+	configs.CertFile, configs.KeyFile = newTestCert(t, 1, nil).writeFiles(t, dir)
+	configs.Logger = logs
+	server := New(configs, mux.NewRouter())
+
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", configs.Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: localhost\r\n\r\n", DefaultPingEndpoint)
+	conn.Close()
+
+	select {
+	case line := <-logs:
+		if !strings.HasPrefix(line, "http: TLS handshake error from ") || strings.HasSuffix(line, "\n") {
+			t.Errorf("Expected: TLS handshake error line; Got: %q", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected: TLS handshake error logged; Got: nothing logged")
+	}
+}
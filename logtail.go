@@ -0,0 +1,131 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// logTail keeps the most recent access log lines in a bounded ring buffer and streams new lines to its subscribers.
+type logTail struct {
+	mu          sync.Mutex
+	lines       []string
+	next        int
+	full        bool
+	subscribers map[chan string]struct{}
+}
+
+func newLogTail(size int) *logTail {
+	if size <= 0 {
+		size = DefaultLogTailSize
+	}
+	return &logTail{
+		lines:       make([]string, size),
+		subscribers: make(map[chan string]struct{}),
+	}
+}
+
+// add appends a line to the buffer, overwriting the oldest line when the buffer is full, and publishes it to all subscribers.
+// Subscribers that can't keep up miss the line rather than blocking the request being logged.
+func (l *logTail) add(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lines[l.next] = line
+	l.next = (l.next + 1) % len(l.lines)
+	if l.next == 0 {
+		l.full = true
+	}
+	for sub := range l.subscribers {
+		select {
+		case sub <- line:
+		default:
+		}
+	}
+}
+
+// subscribe returns the buffered lines, oldest first, and a channel receiving all lines added afterwards.
+// unsubscribe must be called once the caller is no longer interested in new lines.
+func (l *logTail) subscribe() (recent []string, lines chan string, unsubscribe func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.full {
+		recent = append(recent, l.lines[l.next:]...)
+	}
+	recent = append(recent, l.lines[:l.next]...)
+	lines = make(chan string, len(l.lines))
+	l.subscribers[lines] = struct{}{}
+
+	return recent, lines, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.subscribers, lines)
+	}
+}
+
+// middleware records an access log line for each request served by next, ending with the matched route path template,
+// or "-" for unmatched requests.
+func (l *logTail) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := newResponseWriter(w)
+		next.ServeHTTP(rw, r)
+		route := RouteTemplate(r)
+		if route == "" {
+			route = "-"
+		}
+		l.add(fmt.Sprintf("%s \"%s %s %s\" %d %d %s %s", r.RemoteAddr, r.Method, r.RequestURI, r.Proto, rw.status, rw.written, time.Since(start), route))
+	})
+}
+
+// handleFuncLogTail streams the buffered and new access log lines as server-sent events until the client disconnects.
+func (l *logTail) handleFuncLogTail(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	recent, lines, unsubscribe := l.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(200)
+	for _, line := range recent {
+		fmt.Fprintf(w, "data: %s\n\n", line)
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case line := <-lines:
+			fmt.Fprintf(w, "data: %s\n\n", line)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestLogTailShouldStreamAccessLogLines(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+	configs.EnableLogTail = true
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		resp, err := http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, DefaultLogTailEndpoint))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+			t.Errorf("Expected: text/event-stream; Got: %s", contentType)
+		}
+
+		found := make(chan bool)
+		go func() {
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if strings.HasPrefix(scanner.Text(), "data: ") && strings.Contains(scanner.Text(), "\"GET "+DefaultPingEndpoint) {
+					found <- true
+					return
+				}
+			}
+			found <- false
+		}()
+
+		select {
+		case ok := <-found:
+			if !ok {
+				t.Error("Expected: ping access log line streamed; Got: stream closed")
+			}
+		case <-time.After(time.Second):
+			t.Error("Expected: ping access log line streamed; Got: timeout")
+		}
+	})
+}
+
+func TestLogTailShouldLogTheRoutePathTemplate(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/users/{id}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	configs := getTestConfigs()
+	configs.EnableLogTail = true
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		testEndpoint(t, configs.Port, "/users/42", 200)
+		testEndpoint(t, configs.Port, "/unknown", 404)
+
+		recent, _, unsubscribe := s.(*ServerImpl).logTail.subscribe()
+		unsubscribe()
+		var userLine, unknownLine string
+		for _, line := range recent {
+			if strings.Contains(line, "/users/42") {
+				userLine = line
+			}
+			if strings.Contains(line, "/unknown") {
+				unknownLine = line
+			}
+		}
+		if !strings.HasSuffix(userLine, " /users/{id}") {
+			t.Errorf("Expected: access log line ending with the route template; Got: %q", userLine)
+		}
+		if !strings.HasSuffix(unknownLine, " -") {
+			t.Errorf("Expected: access log line ending with -; Got: %q", unknownLine)
+		}
+	})
+}
+
+func TestLogTailShouldKeepOnlyTheMostRecentLines(t *testing.T) {
+	tail := newLogTail(2)
+	tail.add("1")
+	tail.add("2")
+	tail.add("3")
+
+	recent, _, unsubscribe := tail.subscribe()
+	unsubscribe()
+
+	if len(recent) != 2 || recent[0] != "2" || recent[1] != "3" {
+		t.Errorf("Expected: [2 3]; Got: %v", recent)
+	}
+}
+
+func TestLogTailShouldNotBeRegisteredByDefault(t *testing.T) {
+	router := mux.NewRouter()
+	New(getTestConfigs(), router)
+
+	if router.GetRoute(DefaultLogTailEndpoint) != nil {
+		t.Error("Expected: log tail endpoint not configured; Got: configured")
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// SetMaintenanceMode turns the maintenance mode on or off. While on, all requests but the ones to the built-in endpoints
+// are replied with 503 and a Retry-After header holding retryAfter, rounded up to seconds. A zero retryAfter omits the
+// Retry-After header.
+func (s *ServerImpl) SetMaintenanceMode(on bool, retryAfter time.Duration) {
+	atomic.StoreInt32(&s.maintenanceRetryAfter, int32((retryAfter+time.Second-1)/time.Second))
+	if on {
+		atomic.StoreInt32(&s.maintenance, 1)
+	} else {
+		atomic.StoreInt32(&s.maintenance, 0)
+	}
+}
+
+// Pause replies 503 with a Retry-After of 1 second to all requests but the ones to the built-in endpoints until Resume
+// is called, such as for a brief backpressure. As net/http can't pause accepting connections, they are still accepted.
+// Unlike the maintenance mode, meant for planned downtimes announced through its Retry-After, a pause is transient:
+// clients are expected to retry shortly, and it takes precedence over the maintenance mode while both are on.
+func (s *ServerImpl) Pause() {
+	atomic.StoreInt32(&s.paused, 1)
+}
+
+// Resume serves the requests again after Pause.
+func (s *ServerImpl) Resume() {
+	atomic.StoreInt32(&s.paused, 0)
+}
+
+// maintenanceMode replies 503 to the requests to the router routes while the maintenance mode is on or the server is
+// paused.
+func (s *ServerImpl) maintenanceMode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paused := atomic.LoadInt32(&s.paused) == 1
+		if !paused && atomic.LoadInt32(&s.maintenance) == 0 || s.isBuiltinEndpoint(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if paused {
+			w.Header().Set("Retry-After", "1")
+		} else if retryAfter := atomic.LoadInt32(&s.maintenanceRetryAfter); retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+		}
+		s.writeError(w, http.StatusServiceUnavailable)
+	})
+}
+
+// isBuiltinEndpoint returns whether path is served by a built-in endpoint on the main port.
+func (s *ServerImpl) isBuiltinEndpoint(path string) bool {
+	if s.adminRouter != nil {
+		return false
+	}
+	switch path {
+	case s.pingEndpoint, s.healthcheckEndpoint, s.shutdownEndpoint, s.startupEndpoint, s.readinessEndpoint:
+		return true
+	case DefaultLogTailEndpoint:
+		return s.logTail != nil
+	case DefaultEchoEndpoint:
+		return s.Configs.EnableEchoEndpoint
+	}
+	return false
+}
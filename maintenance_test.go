@@ -0,0 +1,69 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMaintenanceModeShouldReply503ToUserRoutesOnly(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/users").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		s.SetMaintenanceMode(true, 90*time.Second)
+		resp := doRequest(t, "GET", configs.Port, "/users", nil)
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Expected: 503; Got: %d", resp.StatusCode)
+		}
+		expectHeader(t, resp, "Retry-After", "90")
+		testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+		testEndpoint(t, configs.Port, DefaultHealthcheckEndpoint, 200)
+
+		s.SetMaintenanceMode(false, 0)
+		testEndpoint(t, configs.Port, "/users", 200)
+	})
+}
+
+func TestPauseShouldReply503ToUserRoutesUntilResume(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/users").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		s.Pause()
+		resp := doRequest(t, "GET", configs.Port, "/users", nil)
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Expected: 503; Got: %d", resp.StatusCode)
+		}
+		expectHeader(t, resp, "Retry-After", "1")
+		testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+		testEndpoint(t, configs.Port, DefaultHealthcheckEndpoint, 200)
+
+		s.Resume()
+		testEndpoint(t, configs.Port, "/users", 200)
+	})
+}
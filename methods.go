@@ -0,0 +1,73 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// notFoundResponse holds the body replied to requests not matching any route when JSONResponses is set.
+type notFoundResponse struct {
+	Error string `json:"error"`
+}
+
+// methodNotAllowedResponse holds the body replied to requests whose path matches a route but not its methods.
+type methodNotAllowedResponse struct {
+	Error          string   `json:"error"`
+	AllowedMethods []string `json:"allowed_methods"`
+}
+
+// handleFuncMethodNotAllowed replies 405 listing the methods of the routes matching the request path in the Allow header
+// and the JSON body.
+func (s *ServerImpl) handleFuncMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	methods := routeMethods(s.currentRouter(), r)
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	if s.Configs.ProblemJSON {
+		WriteProblem(w, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed),
+			"allowed methods: "+strings.Join(methods, ", "))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	json.NewEncoder(w).Encode(methodNotAllowedResponse{
+		Error:          http.StatusText(http.StatusMethodNotAllowed),
+		AllowedMethods: methods,
+	})
+}
+
+// handleFuncNotFound replies 404 with a JSON body.
+func handleFuncNotFound(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(notFoundResponse{Error: http.StatusText(http.StatusNotFound)})
+}
+
+// RegisterNotFoundHandler replaces the handler of the requests not matching any route.
+func (s *ServerImpl) RegisterNotFoundHandler(handler http.Handler) {
+	s.Router.NotFoundHandler = handler
+}
+
+// RegisterMethodNotAllowedHandler replaces the handler of the requests whose path matches a route but not its methods.
+func (s *ServerImpl) RegisterMethodNotAllowedHandler(handler http.Handler) {
+	s.Router.MethodNotAllowedHandler = handler
+}
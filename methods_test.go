@@ -0,0 +1,124 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRequestWithMethodNotAllowedShouldListTheAllowedMethods(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/items").Methods("GET", "PUT").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		resp, err := http.Post(fmt.Sprintf("%s:%d/items", testServerEndpoint, configs.Port), "text/plain", strings.NewReader(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Fatalf("Expected: 405; Got: %d", resp.StatusCode)
+		}
+		expectHeader(t, resp, "Allow", "GET, PUT")
+		body := methodNotAllowedResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(body.AllowedMethods, []string{"GET", "PUT"}) {
+			t.Errorf("Expected: [GET PUT]; Got: %v", body.AllowedMethods)
+		}
+	})
+}
+
+func TestNewServerShouldKeepTheRouterMethodNotAllowedHandler(t *testing.T) {
+	router := mux.NewRouter()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router.MethodNotAllowedHandler = handler
+	New(getTestConfigs(), router)
+
+	if reflect.ValueOf(router.MethodNotAllowedHandler).Pointer() != reflect.ValueOf(handler).Pointer() {
+		t.Error("Expected: router method not allowed handler kept; Got: replaced")
+	}
+}
+
+func TestRequestWithUnmatchedPathShouldBeServedByTheRegisteredNotFoundHandler(t *testing.T) {
+	configs := getTestConfigs()
+	server := New(configs, mux.NewRouter())
+	server.RegisterNotFoundHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	go server.Start()
+	defer server.Stop()
+
+	testEndpoint(t, configs.Port, "/unknown", http.StatusTeapot)
+}
+
+func TestRequestWithMethodNotAllowedShouldBeServedByTheRegisteredHandler(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/items").Methods("POST").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	configs := getTestConfigs()
+	server := New(configs, router)
+	server.RegisterMethodNotAllowedHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Custom", "true")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+
+	go server.Start()
+	defer server.Stop()
+
+	testEndpoint(t, configs.Port, "/items", http.StatusMethodNotAllowed)
+	expectHeader(t, doRequest(t, "GET", configs.Port, "/items", nil), "X-Custom", "true")
+}
+
+func TestServerWithJSONResponsesShouldReplyUnmatchedPathsWithJSON(t *testing.T) {
+	configs := getTestConfigs()
+	configs.JSONResponses = true
+
+	runTestServer(t, configs, mux.NewRouter(), true, nil, func(s Server) {
+		resp, err := http.Get(fmt.Sprintf("%s:%d/unknown", testServerEndpoint, configs.Port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("Expected: 404; Got: %d", resp.StatusCode)
+		}
+		expectHeader(t, resp, "Content-Type", "application/json")
+		body := notFoundResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Error != http.StatusText(http.StatusNotFound) {
+			t.Errorf("Expected: %s; Got: %s", http.StatusText(http.StatusNotFound), body.Error)
+		}
+	})
+}
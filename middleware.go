@@ -0,0 +1,278 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/mux"
+)
+
+type contextKey int
+
+const (
+	// requestStateKey holds the context key of the *requestState of each request.
+	requestStateKey contextKey = iota
+
+	// RequestIDKey holds the context key of the request ID when EnableRequestID is set.
+	RequestIDKey
+)
+
+// requestState holds the server state of an in-flight request. An uninterruptible request is only done once all the
+// goroutines holding it, such as the ones running a handler past its timeout, release it.
+type requestState struct {
+	server          *ServerImpl
+	holders         int32
+	uninterruptible int32
+	routeTemplate   string
+}
+
+func (s *requestState) hold() {
+	atomic.AddInt32(&s.holders, 1)
+}
+
+func (s *requestState) release() {
+	if atomic.AddInt32(&s.holders, -1) == 0 && atomic.LoadInt32(&s.uninterruptible) == 1 {
+		s.server.uninterruptible.done()
+	}
+}
+
+// holdRequest keeps r uninterruptible, if marked so, until the returned func is called, for the goroutines serving r
+// past its handler returning. It must be called while r is being served.
+func holdRequest(r *http.Request) (release func()) {
+	state, ok := r.Context().Value(requestStateKey).(*requestState)
+	if !ok {
+		return func() {}
+	}
+	state.hold()
+	return state.release
+}
+
+// requestGroup counts in-flight requests, allowing callers to wait for all of them to finish.
+type requestGroup struct {
+	mu    sync.Mutex
+	count int
+	idle  chan struct{}
+}
+
+func (g *requestGroup) add() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.count == 0 {
+		g.idle = make(chan struct{})
+	}
+	g.count++
+}
+
+func (g *requestGroup) done() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.count--
+	if g.count == 0 {
+		close(g.idle)
+	}
+}
+
+// len returns the number of requests in the group.
+func (g *requestGroup) len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.count
+}
+
+// wait returns a channel that is closed when there are no requests in the group.
+func (g *requestGroup) wait() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.count == 0 {
+		idle := make(chan struct{})
+		close(idle)
+		return idle
+	}
+	return g.idle
+}
+
+// responseWriter wraps a http.ResponseWriter, recording the status code and the number of bytes written.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	written     int64
+	wroteHeader bool
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+	}
+}
+
+// WriteHeader records the status code and delegates the call to the wrapped writer.
+func (w *responseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the number of bytes written and delegates the call to the wrapped writer.
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush flushes the wrapped writer if it supports flushing.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, allowing http.ResponseController to reach it.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// trackRequests attaches the request state to the context of each request, releasing it once the request is served.
+func (s *ServerImpl) trackRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := &requestState{server: s, holders: 1}
+		s.inFlight.add()
+		s.totalRequests.Add(1)
+		defer func() {
+			s.inFlight.done()
+			state.release()
+		}()
+		w = &hijackWriter{ResponseWriter: w, conns: &s.conns}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestStateKey, state)))
+	})
+}
+
+// MarkUninterruptible marks r as uninterruptible: a graceful shutdown waits for it to finish regardless of
+// ShutdownTimeout, up to the UninterruptibleTimeout, even if its handler outlives the HandlerTimeout or the requested
+// timeout. It must be called while r is being served. Note the request context is still cancelled when shutdown begins.
+func MarkUninterruptible(r *http.Request) {
+	state, ok := r.Context().Value(requestStateKey).(*requestState)
+	if !ok || !atomic.CompareAndSwapInt32(&state.uninterruptible, 0, 1) {
+		return
+	}
+	state.server.uninterruptible.add()
+}
+
+// recordRoute records the path template of the matched route in the request state, so the server middlewares wrapping
+// the router can read it once the request is served.
+func recordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if state, ok := r.Context().Value(requestStateKey).(*requestState); ok {
+			state.routeTemplate = currentRouteTemplate(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RouteTemplate returns the path template of the route matched for r, such as /users/{id}, or an empty string if no
+// route matched. Server middlewares wrapping the router can only read it once the request is served.
+func RouteTemplate(r *http.Request) string {
+	if template := currentRouteTemplate(r); template != "" {
+		return template
+	}
+	if state, ok := r.Context().Value(requestStateKey).(*requestState); ok {
+		return state.routeTemplate
+	}
+	return ""
+}
+
+func currentRouteTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+	template, _ := route.GetPathTemplate()
+	return template
+}
+
+// handler returns the router wrapped by all middlewares enabled in the configs.
+func (s *ServerImpl) handler() http.Handler {
+	var h http.Handler = http.HandlerFunc(s.routeRequests)
+	h = s.captureBodies(h)
+	if s.Configs.RecoverPanics {
+		h = s.recoverPanics(h)
+	}
+	if s.Configs.MaxRequestBodyBytes > 0 {
+		h = s.limitRequestBody(h)
+	}
+	if s.Configs.DecompressRequests {
+		h = s.decompressRequests(h)
+	}
+	h = s.expectContinue(h)
+	if s.Configs.HandlerTimeout > 0 {
+		h = s.handlerTimeout(h)
+	}
+	if s.Configs.RequestTimeout != nil {
+		h = s.requestTimeout(h)
+	}
+	if s.Configs.Compression != nil {
+		h = s.compress(h)
+	}
+	if s.Configs.DisableContentSniffing {
+		h = disableContentSniffing(h)
+	}
+	if s.Configs.CORS != nil {
+		h = s.cors(h)
+	}
+	if s.concurrencyLimiter != nil {
+		h = s.limitConcurrentRequests(h)
+	}
+	if s.rateLimiter != nil {
+		h = s.rateLimiter.middleware(s, h)
+	}
+	if s.ipFilter != nil {
+		h = s.ipFilter.middleware(h)
+	}
+	if s.Configs.MaxURLLength > 0 {
+		h = s.limitURLLength(h)
+	}
+	h = s.maintenanceMode(h)
+	h = s.rejectWhileDraining(h)
+	if s.logTail != nil {
+		h = s.logTail.middleware(h)
+	}
+	if s.Configs.SlowRequestThreshold > 0 {
+		h = s.logSlowRequests(h)
+	}
+	if s.Configs.EnableTracing && s.Configs.Tracer != nil {
+		h = s.trace(h)
+	}
+	if len(s.Configs.DefaultHeaders) > 0 {
+		h = s.defaultHeaders(h)
+	}
+	if s.Configs.EnableRequestID {
+		h = requestID(h)
+	}
+	h = s.injectContextValues(h)
+	return s.trackRequests(h)
+}
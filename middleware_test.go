@@ -0,0 +1,298 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	downloadEndpoint = "/download"
+)
+
+func TestStopShouldWaitForUninterruptibleRequestsBeyondShutdownTimeout(t *testing.T) {
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = 50 * time.Millisecond
+	configs.UninterruptibleTimeout = time.Second
+	router, requestStarted := getUninterruptibleTestRouter(200 * time.Millisecond)
+
+	runTestServer(t, configs, router, false, nil, func(s Server) {
+		statuses := make(chan int)
+		go func() {
+			resp, err := http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, downloadEndpoint))
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+		<-requestStarted
+
+		start := time.Now()
+		if err := s.Stop(); err != nil {
+			t.Errorf("Expected: success; Got: %s", err.Error())
+		}
+		if elapsed := time.Since(start); elapsed < 150*time.Millisecond || elapsed >= configs.UninterruptibleTimeout {
+			t.Errorf("Expected: drain to wait for the uninterruptible request; Got: %s", elapsed)
+		}
+		if status := <-statuses; status != 200 {
+			t.Errorf("Expected: 200; Got: %d", status)
+		}
+	})
+}
+
+func TestStopShouldWaitForUninterruptibleRequestsUpToUninterruptibleTimeout(t *testing.T) {
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = 50 * time.Millisecond
+	configs.UninterruptibleTimeout = 100 * time.Millisecond
+	router, requestStarted := getUninterruptibleTestRouter(time.Second)
+
+	runTestServer(t, configs, router, false, nil, func(s Server) {
+		go http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, downloadEndpoint))
+		<-requestStarted
+
+		start := time.Now()
+		if err := s.Stop(); err != context.DeadlineExceeded {
+			t.Errorf("Expected: %s; Got: %v", context.DeadlineExceeded, err)
+		}
+		if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+			t.Errorf("Expected: drain to stop waiting at the uninterruptible timeout; Got: %s", elapsed)
+		}
+	})
+}
+
+func TestStopShouldWaitForUninterruptibleRequestsOutlivingTheHandlerTimeout(t *testing.T) {
+	var finished int32
+	requestStarted := make(chan struct{})
+	router := mux.NewRouter()
+	router.Path(downloadEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		MarkUninterruptible(r)
+		close(requestStarted)
+		time.Sleep(200 * time.Millisecond)
+		atomic.StoreInt32(&finished, 1)
+	})
+	configs := getTestConfigs()
+	configs.HandlerTimeout = 20 * time.Millisecond
+	configs.ShutdownTimeout = 50 * time.Millisecond
+	configs.UninterruptibleTimeout = time.Second
+
+	runTestServer(t, configs, router, false, nil, func(s Server) {
+		testEndpoint(t, configs.Port, downloadEndpoint, http.StatusServiceUnavailable)
+		<-requestStarted
+
+		if err := s.Stop(); err != nil {
+			t.Errorf("Expected: success; Got: %s", err.Error())
+		}
+		if atomic.LoadInt32(&finished) != 1 {
+			t.Error("Expected: drain to wait for the uninterruptible handler; Got: stopped before it finished")
+		}
+	})
+}
+
+func TestStopShouldNotWaitForTheUninterruptibleTimeoutWithoutInFlightRequests(t *testing.T) {
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = 50 * time.Millisecond
+	configs.UninterruptibleTimeout = time.Second
+
+	runTestServer(t, configs, mux.NewRouter(), false, nil, func(s Server) {
+		conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", configs.Port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		// A partial request leaves the connection active without a request in-flight.
+		fmt.Fprint(conn, "GET / HTTP/1.1\r\n")
+		time.Sleep(20 * time.Millisecond)
+
+		start := time.Now()
+		if err := s.Stop(); err != nil {
+			t.Errorf("Expected: success; Got: %s", err.Error())
+		}
+		if elapsed := time.Since(start); elapsed >= 500*time.Millisecond {
+			t.Errorf("Expected: drain to stop at the shutdown timeout; Got: %s", elapsed)
+		}
+	})
+}
+
+func TestInFlightShouldReportRequestsBeingServedDuringDrain(t *testing.T) {
+	var mu sync.Mutex
+	var progress []int
+	requestStarted := make(chan struct{})
+	router := mux.NewRouter()
+	router.Path(slowEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requestStarted)
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(200)
+	})
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+	configs.DrainProgressInterval = 10 * time.Millisecond
+
+	runTestServer(t, configs, router, false,
+		func(s Server) {
+			s.RegisterDrainProgressHandler(func(inFlight int) {
+				mu.Lock()
+				defer mu.Unlock()
+				progress = append(progress, inFlight)
+			})
+		},
+		func(s Server) {
+			go http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, slowEndpoint))
+			<-requestStarted
+
+			stopped := make(chan error)
+			go func() {
+				stopped <- s.Stop()
+			}()
+			time.Sleep(50 * time.Millisecond)
+			if inFlight := s.InFlight(); inFlight != 1 {
+				t.Errorf("Expected: 1 in-flight request during drain; Got: %d", inFlight)
+			}
+
+			if err := <-stopped; err != nil {
+				t.Errorf("Expected: success; Got: %s", err.Error())
+			}
+			if inFlight := s.InFlight(); inFlight != 0 {
+				t.Errorf("Expected: 0 in-flight requests after drain; Got: %d", inFlight)
+			}
+		})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(progress) == 0 || progress[0] != 1 {
+		t.Errorf("Expected: drain progress starting at 1 in-flight request; Got: %v", progress)
+	}
+}
+
+func getUninterruptibleTestRouter(duration time.Duration) (*mux.Router, chan struct{}) {
+	requestStarted := make(chan struct{})
+	router := mux.NewRouter()
+	router.Path(downloadEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		MarkUninterruptible(r)
+		close(requestStarted)
+		time.Sleep(duration)
+		w.WriteHeader(200)
+	})
+	return router, requestStarted
+}
+
+func TestDrainProgressShouldReportDecreasingInFlightRequestsAndCloseWhenDrained(t *testing.T) {
+	requestsStarted := make(chan struct{}, 2)
+	router := mux.NewRouter()
+	router.Path(slowEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestsStarted <- struct{}{}
+		duration, _ := time.ParseDuration(r.URL.Query().Get("duration"))
+		time.Sleep(duration)
+		w.WriteHeader(200)
+	})
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+	configs.DrainProgressInterval = 10 * time.Millisecond
+
+	var progress []int
+	runTestServer(t, configs, router, false, nil, func(s Server) {
+		go http.Get(fmt.Sprintf("%s:%d%s?duration=100ms", testServerEndpoint, configs.Port, slowEndpoint))
+		go http.Get(fmt.Sprintf("%s:%d%s?duration=200ms", testServerEndpoint, configs.Port, slowEndpoint))
+		<-requestsStarted
+		<-requestsStarted
+
+		drainProgress := s.DrainProgress()
+		stopped := make(chan error)
+		go func() {
+			stopped <- s.Stop()
+		}()
+		for inFlight := range drainProgress {
+			progress = append(progress, inFlight)
+		}
+		if err := <-stopped; err != nil {
+			t.Errorf("Expected: success; Got: %s", err.Error())
+		}
+	})
+
+	if len(progress) == 0 || progress[0] != 2 || progress[len(progress)-1] != 0 {
+		t.Fatalf("Expected: drain progress from 2 to 0 in-flight requests; Got: %v", progress)
+	}
+	for i := 1; i < len(progress); i++ {
+		if progress[i] > progress[i-1] {
+			t.Fatalf("Expected: decreasing drain progress; Got: %v", progress)
+		}
+	}
+}
+
+func TestDrainProgressShouldOpenANewChannelForEachRun(t *testing.T) {
+	configs := getTestConfigs()
+	configs.DrainProgressInterval = 10 * time.Millisecond
+	server := New(configs, mux.NewRouter())
+	started := make(chan struct{})
+	server.RegisterServerStartHandler(func(s *http.Server) error {
+		started <- struct{}{}
+		return nil
+	})
+
+	for run := 1; run <= 2; run++ {
+		startError := make(chan error)
+		go func() {
+			startError <- server.Start()
+		}()
+		<-started
+
+		drainProgress := server.DrainProgress()
+		if err := server.Stop(); err != nil {
+			t.Errorf("Run %d: expected: success; Got: %v", run, err)
+		}
+		var progress []int
+		for inFlight := range drainProgress {
+			progress = append(progress, inFlight)
+		}
+		if len(progress) == 0 || progress[len(progress)-1] != 0 {
+			t.Errorf("Run %d: expected: drain progress ending at 0; Got: %v", run, progress)
+		}
+		if err := <-startError; err != nil {
+			t.Errorf("Run %d: expected: success; Got: %v", run, err)
+		}
+	}
+}
+
+func TestRouteTemplateShouldReturnTheMatchedRoutePathTemplate(t *testing.T) {
+	templates := make(chan string, 1)
+	router := mux.NewRouter()
+	router.Path("/users/{id}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		templates <- RouteTemplate(r)
+	})
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		testEndpoint(t, configs.Port, "/users/42", 200)
+		if template := <-templates; template != "/users/{id}" {
+			t.Errorf("Expected: /users/{id}; Got: %s", template)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// NonceHeader holds the request header carrying the nonce of replay protected requests.
+	NonceHeader = "X-Nonce"
+)
+
+// nonceCache remembers the nonces seen within their TTL, holding up to size nonces.
+type nonceCache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	size  int
+	seen  map[string]time.Time
+	queue []nonceEntry
+	now   func() time.Time
+}
+
+type nonceEntry struct {
+	nonce   string
+	expires time.Time
+}
+
+func newNonceCache(ttl time.Duration, size int) *nonceCache {
+	if ttl <= 0 {
+		ttl = DefaultNonceTTL
+	}
+	if size <= 0 {
+		size = DefaultNonceCacheSize
+	}
+	return &nonceCache{
+		ttl:  ttl,
+		size: size,
+		seen: make(map[string]time.Time),
+		now:  time.Now,
+	}
+}
+
+// add records nonce, returning false if it was already seen within its TTL.
+func (c *nonceCache) add(nonce string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if expires, ok := c.seen[nonce]; ok && now.Before(expires) {
+		return false
+	}
+
+	// As all nonces share the same TTL, the queue is sorted by expiration.
+	for len(c.queue) > 0 && (len(c.queue) >= c.size || !now.Before(c.queue[0].expires)) {
+		oldest := c.queue[0]
+		if c.seen[oldest.nonce] == oldest.expires {
+			delete(c.seen, oldest.nonce)
+		}
+		c.queue = c.queue[1:]
+	}
+	entry := nonceEntry{nonce: nonce, expires: now.Add(c.ttl)}
+	c.seen[nonce] = entry.expires
+	c.queue = append(c.queue, entry)
+	return true
+}
+
+// RegisterReplayProtection requires the requests to the route named routeName to carry an unique nonce in the X-Nonce header.
+// Requests without a nonce are rejected with 400 and requests reusing a nonce seen within the NonceTTL are rejected with 409.
+func (s *ServerImpl) RegisterReplayProtection(routeName string) {
+	if s.replayProtectedRoutes == nil {
+		s.replayProtectedRoutes = make(map[string]bool)
+		s.nonces = newNonceCache(s.Configs.NonceTTL, s.Configs.NonceCacheSize)
+		s.Router.Use(s.protectFromReplay)
+	}
+	s.replayProtectedRoutes[routeName] = true
+}
+
+// protectFromReplay rejects the requests to replay protected routes without a nonce or reusing a nonce.
+func (s *ServerImpl) protectFromReplay(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route == nil || !s.replayProtectedRoutes[route.GetName()] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		nonce := r.Header.Get(NonceHeader)
+		if nonce == "" {
+			s.writeErrorDetail(w, http.StatusBadRequest, "missing "+NonceHeader+" header")
+			return
+		}
+		if !s.nonces.add(nonce) {
+			s.writeErrorDetail(w, http.StatusConflict, "nonce already used")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	adminEndpoint = "/admin"
+)
+
+func TestRegisterReplayProtectionShouldRejectReusedNonces(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path(adminEndpoint).Name(adminEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	})
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterReplayProtection(adminEndpoint)
+		},
+		func(s Server) {
+			if resp := doRequest(t, "GET", configs.Port, adminEndpoint, http.Header{NonceHeader: {"nonce-1"}}); resp.StatusCode != 200 {
+				t.Errorf("Expected: 200; Got: %d", resp.StatusCode)
+			}
+			if resp := doRequest(t, "GET", configs.Port, adminEndpoint, http.Header{NonceHeader: {"nonce-1"}}); resp.StatusCode != http.StatusConflict {
+				t.Errorf("Expected: 409; Got: %d", resp.StatusCode)
+			}
+			if resp := doRequest(t, "GET", configs.Port, adminEndpoint, http.Header{NonceHeader: {"nonce-2"}}); resp.StatusCode != 200 {
+				t.Errorf("Expected: 200; Got: %d", resp.StatusCode)
+			}
+			if resp := doRequest(t, "GET", configs.Port, adminEndpoint, nil); resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected: 400; Got: %d", resp.StatusCode)
+			}
+			testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+		})
+}
+
+func TestNonceCacheShouldForgetNoncesAfterTTLOrWhenFull(t *testing.T) {
+	now := time.Now()
+	cache := newNonceCache(time.Minute, 2)
+	cache.now = func() time.Time { return now }
+
+	cache.add("1")
+	cache.add("2")
+	cache.add("3")
+	if !cache.add("1") {
+		t.Error("Expected: oldest nonce forgotten when the cache is full; Got: remembered")
+	}
+
+	now = now.Add(time.Minute)
+	if !cache.add("3") {
+		t.Error("Expected: nonce forgotten after its TTL; Got: remembered")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrShutdownPhaseCycle is returned when registering a shutdown phase would create a cycle among the phases constraints.
+	ErrShutdownPhaseCycle = errors.New("server: shutdown phases cycle")
+)
+
+// shutdownPhase holds a named step run during shutdown, before all the phases named in before.
+type shutdownPhase struct {
+	name   string
+	before []string
+	fn     func(ctx context.Context) error
+}
+
+// RegisterShutdownPhase registers a named function that runs during shutdown, after the server stopped serving requests,
+// and before the phases named in before. before may name phases not registered yet. An error is returned if the name
+// was already registered or the constraints create a cycle, in which case the phase isn't registered.
+func (s *ServerImpl) RegisterShutdownPhase(name string, before []string, fn func(ctx context.Context) error) error {
+	for _, phase := range s.shutdownPhases {
+		if phase.name == name {
+			return fmt.Errorf("server: shutdown phase %s already registered", name)
+		}
+	}
+	phases := append(append([]*shutdownPhase{}, s.shutdownPhases...), &shutdownPhase{
+		name:   name,
+		before: before,
+		fn:     fn,
+	})
+	if _, err := sortShutdownPhases(phases); err != nil {
+		return err
+	}
+	s.shutdownPhases = phases
+	return nil
+}
+
+// runShutdownPhases runs all phases in topological order, returning the first error. Failing phases don't prevent the
+// following phases from running.
+func (s *ServerImpl) runShutdownPhases(ctx context.Context) error {
+	phases, err := sortShutdownPhases(s.shutdownPhases)
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, phase := range phases {
+		if err := phase.fn(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// sortShutdownPhases sorts phases topologically, breaking ties by registration order.
+func sortShutdownPhases(phases []*shutdownPhase) ([]*shutdownPhase, error) {
+	registered := make(map[string]bool, len(phases))
+	for _, phase := range phases {
+		registered[phase.name] = true
+	}
+	pending := make(map[string]int, len(phases))
+	for _, phase := range phases {
+		for _, next := range phase.before {
+			if registered[next] {
+				pending[next]++
+			}
+		}
+	}
+
+	sorted := make([]*shutdownPhase, 0, len(phases))
+	done := make(map[string]bool, len(phases))
+	for len(sorted) < len(phases) {
+		var ready *shutdownPhase
+		for _, phase := range phases {
+			if !done[phase.name] && pending[phase.name] == 0 {
+				ready = phase
+				break
+			}
+		}
+		if ready == nil {
+			var cycle []string
+			for _, phase := range phases {
+				if !done[phase.name] {
+					cycle = append(cycle, phase.name)
+				}
+			}
+			return nil, fmt.Errorf("%w: %s", ErrShutdownPhaseCycle, strings.Join(cycle, ", "))
+		}
+
+		done[ready.name] = true
+		sorted = append(sorted, ready)
+		for _, next := range ready.before {
+			if registered[next] {
+				pending[next]--
+			}
+		}
+	}
+	return sorted, nil
+}
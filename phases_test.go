@@ -0,0 +1,79 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRegisterShutdownPhaseShouldRunPhasesInTopologicalOrder(t *testing.T) {
+	var order []string
+	phase := func(name string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			order = append(order, name)
+			return nil
+		}
+	}
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			if err := s.RegisterShutdownPhase("close-db", nil, phase("close-db")); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.RegisterShutdownPhase("flush-cache", []string{"close-db"}, phase("flush-cache")); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.RegisterShutdownPhase("stop-workers", []string{"flush-cache", "close-db"}, phase("stop-workers")); err != nil {
+				t.Fatal(err)
+			}
+		},
+		nil)
+
+	expected := []string{"stop-workers", "flush-cache", "close-db"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected: %v; Got: %v", expected, order)
+	}
+}
+
+func TestRegisterShutdownPhaseShouldReturnErrorOnCycles(t *testing.T) {
+	server := New(getTestConfigs(), mux.NewRouter())
+	noop := func(ctx context.Context) error { return nil }
+
+	if err := server.RegisterShutdownPhase("a", []string{"b"}, noop); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterShutdownPhase("b", []string{"c"}, noop); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterShutdownPhase("c", []string{"a"}, noop); !errors.Is(err, ErrShutdownPhaseCycle) {
+		t.Errorf("Expected: %s; Got: %v", ErrShutdownPhaseCycle, err)
+	}
+	if err := server.RegisterShutdownPhase("a", nil, noop); err == nil {
+		t.Error("Expected: error as the phase is already registered; Got: success")
+	}
+}
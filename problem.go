@@ -0,0 +1,77 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	// ProblemContentType holds the content type of the RFC 7807 problem details bodies.
+	ProblemContentType = "application/problem+json"
+)
+
+// problemResponse holds a RFC 7807 problem details body.
+type problemResponse struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// WriteProblem replies status with a RFC 7807 application/problem+json body holding title and detail. The problem type
+// is about:blank, so title should be the status text. An empty detail is omitted.
+func WriteProblem(w http.ResponseWriter, status int, title, detail string) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problemResponse{Type: "about:blank", Title: title, Status: status, Detail: detail})
+}
+
+// writeError replies status with its status text, as a problem details body if problemJSON is set or as plain text.
+func writeError(w http.ResponseWriter, status int, problemJSON bool) {
+	if problemJSON {
+		WriteProblem(w, status, http.StatusText(status), "")
+		return
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// writeError replies status with its status text, honoring the ProblemJSON configs.
+func (s *ServerImpl) writeError(w http.ResponseWriter, status int) {
+	writeError(w, status, s.Configs.ProblemJSON)
+}
+
+// writeErrorDetail replies status with detail, as the detail of a problem details body if the ProblemJSON configs is
+// set or as plain text.
+func (s *ServerImpl) writeErrorDetail(w http.ResponseWriter, status int, detail string) {
+	if s.Configs.ProblemJSON {
+		WriteProblem(w, status, http.StatusText(status), detail)
+		return
+	}
+	http.Error(w, detail, status)
+}
+
+// handleFuncProblemNotFound replies 404 with a problem details body.
+func handleFuncProblemNotFound(w http.ResponseWriter, r *http.Request) {
+	WriteProblem(w, http.StatusNotFound, http.StatusText(http.StatusNotFound), "")
+}
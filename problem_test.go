@@ -0,0 +1,131 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServerWithProblemJSONShouldReplyUnmatchedPathsWithAProblem(t *testing.T) {
+	configs := getTestConfigs()
+	configs.ProblemJSON = true
+
+	runTestServer(t, configs, mux.NewRouter(), true, nil, func(s Server) {
+		status, problem := getProblem(t, "GET", configs.Port, "/missing")
+
+		if status != http.StatusNotFound {
+			t.Errorf("Expected: 404; Got: %d", status)
+		}
+		expected := problemResponse{Type: "about:blank", Title: "Not Found", Status: http.StatusNotFound}
+		if problem != expected {
+			t.Errorf("Expected: %+v; Got: %+v", expected, problem)
+		}
+	})
+}
+
+func TestServerWithProblemJSONShouldReplyTheServerErrorsWithProblems(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/items").Methods("GET").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	configs := getTestConfigs()
+	configs.ProblemJSON = true
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		status, problem := getProblem(t, "DELETE", configs.Port, "/items")
+		if status != http.StatusMethodNotAllowed || problem.Detail != "allowed methods: GET" {
+			t.Errorf("Expected: 405 allowing GET; Got: %d %+v", status, problem)
+		}
+
+		s.SetMaintenanceMode(true, time.Second)
+		status, problem = getProblem(t, "GET", configs.Port, "/items")
+		if status != http.StatusServiceUnavailable || problem.Title != "Service Unavailable" {
+			t.Errorf("Expected: 503 problem; Got: %d %+v", status, problem)
+		}
+	})
+}
+
+func TestServerWithProblemJSONShouldReplyTheMiddlewareErrorsWithProblems(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path(slowEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	router.Path("/orders").Name("orders").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	configs := getTestConfigs()
+	configs.ProblemJSON = true
+	configs.HandlerTimeout = 20 * time.Millisecond
+	configs.DeniedCIDRs = []string{"10.0.0.0/8"}
+	configs.TrustedProxies = []string{"127.0.0.1", "::1"}
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterReplayProtection("orders")
+		},
+		func(s Server) {
+			status, problem := getProblem(t, "GET", configs.Port, slowEndpoint)
+			if status != http.StatusServiceUnavailable || problem.Detail != DefaultHandlerTimeoutMessage {
+				t.Errorf("Expected: 503 problem with the timeout message; Got: %d %+v", status, problem)
+			}
+			status, problem = getProblem(t, "GET", configs.Port, "/orders")
+			if status != http.StatusBadRequest || problem.Detail != "missing "+NonceHeader+" header" {
+				t.Errorf("Expected: 400 problem with the missing nonce; Got: %d %+v", status, problem)
+			}
+
+			req, err := http.NewRequest("GET", fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, "/orders"), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Forwarded-For", "10.1.2.3")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusForbidden {
+				t.Errorf("Expected: 403; Got: %d", resp.StatusCode)
+			}
+			expectHeader(t, resp, "Content-Type", ProblemContentType)
+		})
+}
+
+// getProblem issues a request with method to path, returning the status code and the decoded problem details body.
+func getProblem(t *testing.T, method string, port int, path string) (int, problemResponse) {
+	t.Helper()
+	req, err := http.NewRequest(method, fmt.Sprintf("%s:%d%s", testServerEndpoint, port, path), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	expectHeader(t, resp, "Content-Type", ProblemContentType)
+	problem := problemResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, problem
+}
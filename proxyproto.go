@@ -0,0 +1,189 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultProxyProtocolHeaderTimeout holds the maximum time trusted sources have to send the PROXY protocol header.
+	DefaultProxyProtocolHeaderTimeout = 5 * time.Second
+
+	// proxyProtocolV1MaxLength holds the maximum length of a PROXY protocol v1 header, including the CRLF.
+	proxyProtocolV1MaxLength = 107
+)
+
+var (
+	// ErrInvalidProxyProtocolHeader is returned when reading from a connection sending an invalid PROXY protocol header.
+	ErrInvalidProxyProtocolHeader = errors.New("server: invalid PROXY protocol header")
+
+	// proxyProtocolV2Signature holds the signature starting the PROXY protocol v2 headers.
+	proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// proxyProtocolListener wraps the accepted connections so the PROXY protocol header sent by trusted sources sets their
+// remote address.
+type proxyProtocolListener struct {
+	net.Listener
+	trusted []*net.IPNet
+}
+
+// Accept waits for and returns the next connection. The header is only read on the first use of the connection, so a
+// slow client can't block the accepting loop.
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyProtocolConn{
+		Conn:    conn,
+		trusted: containsIP(l.trusted, hostIP(conn.RemoteAddr().String())),
+	}, nil
+}
+
+// proxyProtocolConn reads the PROXY protocol header of trusted sources before the first read or address lookup.
+type proxyProtocolConn struct {
+	net.Conn
+	trusted    bool
+	once       sync.Once
+	reader     io.Reader
+	remoteAddr net.Addr
+	err        error
+}
+
+// Read reads from the connection, after its PROXY protocol header.
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address sent in the PROXY protocol header, or the connection remote address otherwise.
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readHeader reads the PROXY protocol v1 or v2 header of trusted sources. Connections without a header are served as
+// is; connections with an invalid header fail to read.
+func (c *proxyProtocolConn) readHeader() {
+	if !c.trusted {
+		c.reader = c.Conn
+		return
+	}
+	c.Conn.SetReadDeadline(time.Now().Add(DefaultProxyProtocolHeaderTimeout))
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	reader := bufio.NewReader(c.Conn)
+	c.reader = reader
+	if prefix, err := reader.Peek(len(proxyProtocolV2Signature)); err == nil && bytes.Equal(prefix, proxyProtocolV2Signature) {
+		c.remoteAddr, c.err = readProxyProtocolV2(reader)
+	} else if prefix, err := reader.Peek(6); err == nil && string(prefix) == "PROXY " {
+		c.remoteAddr, c.err = readProxyProtocolV1(reader)
+	}
+	if c.err != nil {
+		c.Conn.Close()
+	}
+}
+
+// readProxyProtocolV1 reads a "PROXY TCP4|TCP6|UNKNOWN src dst sport dport\r\n" header, returning nil for UNKNOWN.
+func readProxyProtocolV1(reader *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyProtocolV1MaxLength {
+			return nil, ErrInvalidProxyProtocolHeader
+		}
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, ErrInvalidProxyProtocolHeader
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.Atoi(fields[4])
+	if ip == nil || err != nil || port < 0 || port > 65535 {
+		return nil, ErrInvalidProxyProtocolHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// readProxyProtocolV2 reads a binary header, returning nil for LOCAL commands and non-TCP address families.
+func readProxyProtocolV2(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidProxyProtocolHeader, header[12]>>4)
+	}
+	addresses := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(reader, addresses); err != nil {
+		return nil, err
+	}
+
+	command, family := header[12]&0x0f, header[13]
+	if command > 1 {
+		return nil, fmt.Errorf("%w: unsupported command %d", ErrInvalidProxyProtocolHeader, command)
+	}
+	if command == 0 {
+		// LOCAL connections, such as the proxy health checks, keep their own address.
+		return nil, nil
+	}
+	var ipLength int
+	switch family {
+	case 0x11:
+		ipLength = net.IPv4len
+	case 0x21:
+		ipLength = net.IPv6len
+	default:
+		return nil, nil
+	}
+	if len(addresses) < 2*ipLength+4 {
+		return nil, ErrInvalidProxyProtocolHeader
+	}
+	ip := net.IP(addresses[:ipLength])
+	port := binary.BigEndian.Uint16(addresses[2*ipLength:])
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
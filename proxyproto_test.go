@@ -0,0 +1,109 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	remoteAddrEndpoint = "/remote-addr"
+)
+
+func TestServerWithProxyProtocolShouldServeTheForwardedClientAddress(t *testing.T) {
+	v2Header := append([]byte{}, proxyProtocolV2Signature...)
+	v2Header = append(v2Header, 0x21, 0x11, 0, 12, 203, 0, 113, 8, 10, 0, 0, 1)
+	v2Header = binary.BigEndian.AppendUint16(v2Header, 4343)
+	v2Header = binary.BigEndian.AppendUint16(v2Header, 80)
+
+	configs := getTestConfigs()
+	configs.EnableProxyProtocol = true
+	configs.ProxyProtocolTrustedCIDRs = []string{"127.0.0.1"}
+
+	runTestServer(t, configs, getRemoteAddrTestRouter(), true, nil, func(s Server) {
+		if status, body := sendProxyProtocolRequest(t, configs.Port, []byte("PROXY TCP4 203.0.113.7 10.0.0.1 4242 80\r\n")); status != 200 || body != "203.0.113.7:4242" {
+			t.Errorf("Expected: 200 203.0.113.7:4242; Got: %d %s", status, body)
+		}
+		if status, body := sendProxyProtocolRequest(t, configs.Port, v2Header); status != 200 || body != "203.0.113.8:4343" {
+			t.Errorf("Expected: 200 203.0.113.8:4343; Got: %d %s", status, body)
+		}
+	})
+}
+
+func TestServerWithProxyProtocolShouldIgnoreHeadersFromUntrustedSources(t *testing.T) {
+	configs := getTestConfigs()
+	configs.EnableProxyProtocol = true
+	configs.ProxyProtocolTrustedCIDRs = []string{"10.0.0.0/8"}
+
+	runTestServer(t, configs, getRemoteAddrTestRouter(), true, nil, func(s Server) {
+		if status, _ := sendProxyProtocolRequest(t, configs.Port, []byte("PROXY TCP4 203.0.113.7 10.0.0.1 4242 80\r\n")); status != 400 {
+			t.Errorf("Expected: 400; Got: %d", status)
+		}
+	})
+}
+
+func TestServerWithProxyProtocolWithoutTrustedCIDRsShouldReturnErrorOnStart(t *testing.T) {
+	configs := getTestConfigs()
+	configs.EnableProxyProtocol = true
+	server := New(configs, mux.NewRouter())
+
+	if err := server.Start(); err != ErrProxyProtocolUntrusted {
+		t.Errorf("Expected: %v; Got: %v", ErrProxyProtocolUntrusted, err)
+	}
+}
+
+func getRemoteAddrTestRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.Path(remoteAddrEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.RemoteAddr)
+	})
+	return router
+}
+
+// sendProxyProtocolRequest sends header followed by a request to the remote address endpoint on a new connection.
+func sendProxyProtocolRequest(t *testing.T, port int, header []byte) (int, string) {
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write(header)
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n", remoteAddrEndpoint)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
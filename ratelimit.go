@@ -0,0 +1,152 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"container/list"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRateLimitMaxClients holds the default maximum number of clients whose rate limit is tracked.
+	DefaultRateLimitMaxClients = 100000
+)
+
+// RateLimitConfig holds the per-client rate limiting configs.
+// RequestsPerSecond holds the rate each client's token bucket is refilled at.
+// Burst holds the maximum number of requests a client can send at once. Defaults to RequestsPerSecond, rounded up.
+// Key optionally returns the key identifying the client of a request. Defaults to ClientIP.
+// MaxClients holds the maximum number of clients tracked. Once reached, the least recently seen client is forgotten,
+// getting a full bucket back on its next request. Defaults to DefaultRateLimitMaxClients.
+// ExemptPaths holds the paths that are never rate limited. The built-in endpoints are always exempt.
+type RateLimitConfig struct {
+	RequestsPerSecond float64
+	Burst             int
+	Key               func(r *http.Request) string
+	MaxClients        int
+	ExemptPaths       []string
+}
+
+// rateLimiter limits the rate of requests of each client with a token bucket per client. The buckets are kept in least
+// recently seen order, so forgetting a client once MaxClients are tracked takes constant time.
+type rateLimiter struct {
+	mu          sync.Mutex
+	rate        float64
+	burst       float64
+	maxClients  int
+	key         func(r *http.Request) string
+	exemptPaths map[string]bool
+	problemJSON bool
+	buckets     map[string]*list.Element
+	seen        *list.List
+	now         func() time.Time
+}
+
+// tokenBucket holds the tokens left to the client identified by key as of updated.
+type tokenBucket struct {
+	key     string
+	tokens  float64
+	updated time.Time
+}
+
+func newRateLimiter(configs *Configs) (*rateLimiter, error) {
+	c := configs.RateLimit
+	if c.RequestsPerSecond <= 0 {
+		return nil, errors.New("server: rate limit requests per second must be positive")
+	}
+	burst := c.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(c.RequestsPerSecond))
+	}
+	maxClients := c.MaxClients
+	if maxClients <= 0 {
+		maxClients = DefaultRateLimitMaxClients
+	}
+	key := c.Key
+	if key == nil {
+		key = ClientIP
+	}
+	exemptPaths := make(map[string]bool, len(c.ExemptPaths))
+	for _, path := range c.ExemptPaths {
+		exemptPaths[path] = true
+	}
+	return &rateLimiter{
+		rate:        c.RequestsPerSecond,
+		burst:       float64(burst),
+		maxClients:  maxClients,
+		key:         key,
+		exemptPaths: exemptPaths,
+		problemJSON: configs.ProblemJSON,
+		buckets:     make(map[string]*list.Element),
+		seen:        list.New(),
+		now:         time.Now,
+	}, nil
+}
+
+// allow takes a token from the bucket of key, returning false and the time until a token is available otherwise.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var bucket *tokenBucket
+	if element, ok := l.buckets[key]; ok {
+		l.seen.MoveToFront(element)
+		bucket = element.Value.(*tokenBucket)
+	} else {
+		if len(l.buckets) >= l.maxClients {
+			oldest := l.seen.Back()
+			l.seen.Remove(oldest)
+			delete(l.buckets, oldest.Value.(*tokenBucket).key)
+		}
+		bucket = &tokenBucket{key: key, tokens: l.burst, updated: now}
+		l.buckets[key] = l.seen.PushFront(bucket)
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate)
+	bucket.updated = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// middleware replies 429 with a Retry-After header to the clients exceeding their rate limit. The requests to the
+// built-in endpoints of s are never limited, so probes keep working whatever the clients' own traffic.
+func (l *rateLimiter) middleware(s *ServerImpl, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.exemptPaths[r.URL.Path] || s.isBuiltinEndpoint(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ok, retryAfter := l.allow(l.key(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, l.problemJSON)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServerWithRateLimitShouldRejectClientsExceedingTheLimit(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/users").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	configs := getTestConfigs()
+	configs.RateLimit = &RateLimitConfig{RequestsPerSecond: 0.1, Burst: 5}
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		var limited *http.Response
+		for i := 0; i < 10 && limited == nil; i++ {
+			if resp := doRequest(t, "GET", configs.Port, "/users", nil); resp.StatusCode == http.StatusTooManyRequests {
+				limited = resp
+			}
+		}
+		if limited == nil {
+			t.Fatal("Expected: 429 once the burst is exhausted; Got: none")
+		}
+		if limited.Header.Get("Retry-After") == "" {
+			t.Error("Expected: Retry-After header; Got: none")
+		}
+
+		for i := 0; i < 10; i++ {
+			testEndpoint(t, configs.Port, DefaultHealthcheckEndpoint, 200)
+			testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+		}
+	})
+}
+
+func TestServerWithRateLimitShouldExemptTheRegisteredHealthcheckEndpoint(t *testing.T) {
+	configs := getTestConfigs()
+	configs.RateLimit = &RateLimitConfig{RequestsPerSecond: 0.1, Burst: 1}
+
+	runTestServer(t, configs, mux.NewRouter(), true,
+		func(s Server) {
+			s.RegisterHealthcheckEndpoint(customHealthcheckEndpoint, func(w http.ResponseWriter, r *http.Request) {})
+		},
+		func(s Server) {
+			for i := 0; i < 5; i++ {
+				testEndpoint(t, configs.Port, customHealthcheckEndpoint, 200)
+			}
+			doRequest(t, "GET", configs.Port, DefaultHealthcheckEndpoint, nil)
+			testEndpoint(t, configs.Port, DefaultHealthcheckEndpoint, http.StatusTooManyRequests)
+		})
+}
+
+func TestRateLimiterShouldRefillTheTokensOverTime(t *testing.T) {
+	configs := getTestConfigs()
+	configs.RateLimit = &RateLimitConfig{RequestsPerSecond: 2, Burst: 1}
+	limiter, err := newRateLimiter(configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	if ok, _ := limiter.allow("client"); !ok {
+		t.Fatal("Expected: first request allowed; Got: rejected")
+	}
+	ok, retryAfter := limiter.allow("client")
+	if ok {
+		t.Fatal("Expected: second request rejected; Got: allowed")
+	}
+	if retryAfter != 500*time.Millisecond {
+		t.Errorf("Expected: retry after 500ms; Got: %s", retryAfter)
+	}
+	if ok, _ := limiter.allow("other-client"); !ok {
+		t.Error("Expected: other client allowed; Got: rejected")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := limiter.allow("client"); !ok {
+		t.Error("Expected: request allowed once refilled; Got: rejected")
+	}
+}
+
+func TestRateLimiterShouldForgetTheLeastRecentlySeenClientOnceFull(t *testing.T) {
+	configs := getTestConfigs()
+	configs.RateLimit = &RateLimitConfig{RequestsPerSecond: 1, Burst: 1, MaxClients: 2}
+	limiter, err := newRateLimiter(configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	limiter.allow("first")
+	limiter.allow("second")
+	limiter.allow("first")
+	limiter.allow("third")
+	if len(limiter.buckets) != 2 {
+		t.Errorf("Expected: 2 clients tracked; Got: %d", len(limiter.buckets))
+	}
+	if ok, _ := limiter.allow("first"); ok {
+		t.Error("Expected: recently seen client still limited; Got: allowed")
+	}
+	if ok, _ := limiter.allow("second"); !ok {
+		t.Error("Expected: forgotten client allowed; Got: rejected")
+	}
+}
+
+func TestServerWithInvalidRateLimitShouldReturnErrorOnStart(t *testing.T) {
+	configs := getTestConfigs()
+	configs.RateLimit = &RateLimitConfig{}
+	server := New(configs, mux.NewRouter())
+
+	if err := server.Start(); err == nil {
+		t.Error("Expected: error as the rate is not valid; Got: success")
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// RegisterRawHandler registers handler for the requests with method to path, bypassing the router middlewares, such as
+// authentication ones added with Router.Use. The server middlewares enabled in the configs still apply. The path
+// variables are available through mux.Vars, but mux.CurrentRoute returns nil. The built-in endpoints are raw routes too.
+func (s *ServerImpl) RegisterRawHandler(method, path string, handler http.HandlerFunc) {
+	s.rawRoute(s.Router.Methods(method).Path(path).Handler(handler))
+}
+
+// rawRoute marks route as raw, so its handler is served without the router middlewares.
+func (s *ServerImpl) rawRoute(route *mux.Route) *mux.Route {
+	s.rawRoutes[route] = true
+	return route
+}
+
+// routeRequests serves r with the active router, serving the requests matching a raw route with the route handler
+// directly, skipping the router middlewares.
+func (s *ServerImpl) routeRequests(w http.ResponseWriter, r *http.Request) {
+	active := s.routing.Load()
+	var match mux.RouteMatch
+	if len(active.rawRoutes) == 0 || !active.router.Match(r, &match) || match.MatchErr != nil || !active.rawRoutes[match.Route] {
+		active.router.ServeHTTP(w, r)
+		return
+	}
+	if state, ok := r.Context().Value(requestStateKey).(*requestState); ok {
+		state.routeTemplate, _ = match.Route.GetPathTemplate()
+	}
+	match.Route.GetHandler().ServeHTTP(w, mux.SetURLVars(r, match.Vars))
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRawHandlersShouldBypassTheRouterMiddlewares(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	server := New(configs, router)
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+	})
+	server.RegisterRawHandler("GET", "/raw/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Id", mux.Vars(r)["id"])
+		w.Header().Set("X-Route", RouteTemplate(r))
+	})
+	server.Handle("GET", "/wrapped", func(w http.ResponseWriter, r *http.Request) {})
+
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+
+	resp := doRequest(t, "GET", configs.Port, "/raw/1", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected: 200; Got: %d", resp.StatusCode)
+	}
+	expectHeader(t, resp, "X-Id", "1")
+	expectHeader(t, resp, "X-Route", "/raw/{id}")
+	testEndpoint(t, configs.Port, "/wrapped", http.StatusUnauthorized)
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// RegisterInternalErrorHandler registers a function replying the requests whose handler panicked when RecoverPanics is
+// set, such as to reply a generic message along with the request ID. recovered holds the value passed to panic. The
+// handler isn't called if the panicking handler already wrote its response.
+func (s *ServerImpl) RegisterInternalErrorHandler(f func(w http.ResponseWriter, r *http.Request, recovered interface{})) {
+	s.internalErrorHandler = f
+}
+
+// recoverPanics recovers the panics of the handlers, logging them with their stack trace and replying 500 through the
+// internal error handler, or with no details about the panic if none is registered. http.ErrAbortHandler panics are
+// left to the HTTP server, which aborts the response silently.
+func (s *ServerImpl) recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := newResponseWriter(w)
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			s.logf("server: panic serving %s %s: %v\n%s", r.Method, r.URL.Path, recovered, debug.Stack())
+			if rw.wroteHeader {
+				return
+			}
+			if s.internalErrorHandler != nil {
+				s.internalErrorHandler(w, r, recovered)
+				return
+			}
+			s.writeError(w, http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
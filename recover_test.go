@@ -0,0 +1,97 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	panicEndpoint = "/panic"
+)
+
+func TestRecoverPanicsShouldReply500WithoutLeakingThePanic(t *testing.T) {
+	configs := getTestConfigs()
+	configs.RecoverPanics = true
+	server := New(configs, newPanicRouter())
+	server.GetHTTPServer().ErrorLog = log.New(ioutil.Discard, "", 0)
+
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+
+	status, body := getBody(t, configs.Port, panicEndpoint)
+	if status != http.StatusInternalServerError || strings.Contains(body, "secret") {
+		t.Errorf("Expected: 500 without the panic details; Got: %d %q", status, body)
+	}
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+}
+
+func TestInternalErrorHandlerShouldReplyThePanickingRequests(t *testing.T) {
+	configs := getTestConfigs()
+	configs.RecoverPanics = true
+	configs.EnableRequestID = true
+	server := New(configs, newPanicRouter())
+	server.GetHTTPServer().ErrorLog = log.New(ioutil.Discard, "", 0)
+	var recovered interface{}
+	server.RegisterInternalErrorHandler(func(w http.ResponseWriter, r *http.Request, p interface{}) {
+		recovered = p
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "internal error, request %s", RequestIDFromContext(r.Context()))
+	})
+
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, panicEndpoint), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(RequestIDHeader, "abc")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusInternalServerError || string(body) != "internal error, request abc" {
+		t.Errorf("Expected: 500 internal error, request abc; Got: %d %q", resp.StatusCode, body)
+	}
+	if recovered != "secret failure" {
+		t.Errorf("Expected: the recovered panic value; Got: %v", recovered)
+	}
+}
+
+func newPanicRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.Path(panicEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("secret failure")
+	})
+	return router
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServerWithRequestIDShouldGenerateRequestIDs(t *testing.T) {
+	ids := make(chan string, 1)
+	router := mux.NewRouter()
+	router.Path("/users").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids <- RequestIDFromContext(r.Context())
+	})
+	configs := getTestConfigs()
+	configs.EnableRequestID = true
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		resp := doRequest(t, "GET", configs.Port, "/users", nil)
+		id := resp.Header.Get(RequestIDHeader)
+		if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+			t.Errorf("Expected: UUID request ID; Got: %q", id)
+		}
+		if contextID := <-ids; contextID != id {
+			t.Errorf("Expected: %s; Got: %s", id, contextID)
+		}
+	})
+}
+
+func TestServerWithRequestIDShouldPreserveInboundRequestIDs(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.EnableRequestID = true
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		resp := doRequest(t, "GET", configs.Port, DefaultPingEndpoint, http.Header{RequestIDHeader: {"inbound-id"}})
+		expectHeader(t, resp, RequestIDHeader, "inbound-id")
+	})
+}
+
+func TestServerWithoutRequestIDShouldNotSetRequestIDs(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		resp := doRequest(t, "GET", configs.Port, DefaultPingEndpoint, nil)
+		expectHeader(t, resp, RequestIDHeader, "")
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+const (
+	// minAcceptRetryDelay holds the delay before the first retry of a failed accept, doubling on each consecutive failure.
+	minAcceptRetryDelay = 5 * time.Millisecond
+
+	// maxAcceptRetryDelay holds the maximum delay between the retries of a failed accept.
+	maxAcceptRetryDelay = time.Second
+)
+
+// retryListener retries accepting connections with backoff on temporary and timeout errors, returning any other error.
+type retryListener struct {
+	net.Listener
+	logf func(format string, args ...interface{})
+}
+
+// Accept waits for the next connection, retrying the temporary and timeout errors.
+func (l *retryListener) Accept() (net.Conn, error) {
+	var delay time.Duration
+	for {
+		conn, err := l.Listener.Accept()
+		if err == nil || !isRetryableAcceptError(err) {
+			return conn, err
+		}
+		if delay == 0 {
+			delay = minAcceptRetryDelay
+		} else if delay *= 2; delay > maxAcceptRetryDelay {
+			delay = maxAcceptRetryDelay
+		}
+		l.logf("server: accept error: %v; retrying in %v", err, delay)
+		time.Sleep(delay)
+	}
+}
+
+func isRetryableAcceptError(err error) bool {
+	if errors.Is(err, net.ErrClosed) {
+		return false
+	}
+	var netErr net.Error
+	// Temporary is deprecated, but custom listeners may still report transient errors through it.
+	return errors.As(err, &netErr) && (netErr.Timeout() || netErr.Temporary())
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// timeoutError is a net.Error reporting a timeout without being temporary, which the HTTP server doesn't retry.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "accept timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return false }
+
+// flakyListener fails its first Accept with a timeout error.
+type flakyListener struct {
+	net.Listener
+	failed int32
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if atomic.CompareAndSwapInt32(&l.failed, 0, 1) {
+		return nil, timeoutError{}
+	}
+	return l.Listener.Accept()
+}
+
+func TestServerWithRetryAcceptErrorsShouldKeepServingAfterATimeoutAcceptError(t *testing.T) {
+	configs := getTestConfigs()
+	configs.RetryAcceptErrors = true
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", configs.Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := &flakyListener{Listener: l}
+	server := New(configs, mux.NewRouter())
+	server.RegisterListener(listener)
+
+	go server.Start()
+	defer server.Stop()
+
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+	if atomic.LoadInt32(&listener.failed) != 1 {
+		t.Error("Expected: accept to fail once; Got: no failure")
+	}
+}
+
+func TestServerWithoutRetryAcceptErrorsShouldFailOnATimeoutAcceptError(t *testing.T) {
+	configs := getTestConfigs()
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", configs.Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := New(configs, mux.NewRouter())
+	server.RegisterListener(&flakyListener{Listener: l})
+
+	if err := server.Start(); err == nil {
+		t.Error("Expected: accept error returned; Got: success")
+	}
+}
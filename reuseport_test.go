@@ -0,0 +1,48 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build linux
+// +build linux
+
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServerWithReusePortShouldShareItsPortWithOtherListeners(t *testing.T) {
+	configs := getTestConfigs()
+	configs.EnableReusePort = true
+	server := New(configs, mux.NewRouter())
+	go server.Start()
+	defer server.Stop()
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+
+	config := net.ListenConfig{Control: reusePortControl}
+	l, err := config.Listen(context.Background(), "tcp", server.GetHTTPServer().Addr)
+	if err != nil {
+		t.Fatalf("Expected: second listener on the same port; Got: %v", err)
+	}
+	l.Close()
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// routing holds the router serving the requests along with its raw routes, so UpdateRouter swaps both at once.
+type routing struct {
+	router    *mux.Router
+	rawRoutes map[*mux.Route]bool
+}
+
+// RouteInfo holds the details of a registered route.
+// Name holds the route name, empty for unnamed routes.
+// Path holds the route path template, such as /users/{id}.
+// Methods holds the methods matched by the route, empty if it matches all methods.
+// Admin tells whether the route is served on the AdminPort.
+type RouteInfo struct {
+	Name    string
+	Path    string
+	Methods []string
+	Admin   bool
+}
+
+// Handle registers handler for the requests with method to path. The path can hold variables, such as /users/{id}.
+func (s *ServerImpl) Handle(method, path string, handler http.HandlerFunc) {
+	s.Router.Methods(method).Path(path).Handler(handler)
+}
+
+// HandlePrefix registers handler for the requests to all paths starting with prefix, whatever their method.
+// The prefix is not stripped from the request path.
+func (s *ServerImpl) HandlePrefix(prefix string, handler http.Handler) {
+	s.Router.PathPrefix(prefix).Handler(handler)
+}
+
+// HandleWithMiddleware registers handler for the requests with method to path, wrapped by mw in order, the first being
+// the outermost. Unlike the router middlewares, mw only applies to this route, such as to enable CORS or authentication
+// on a subset of the routes.
+func (s *ServerImpl) HandleWithMiddleware(method, path string, handler http.HandlerFunc, mw ...mux.MiddlewareFunc) {
+	var h http.Handler = handler
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	s.Router.Methods(method).Path(path).Handler(h)
+}
+
+// Group returns a subrouter matching the paths starting with prefix, wrapped by mw in order, the first being the
+// outermost, such as for a versioned API. The routes registered on the group are relative to prefix and mw only applies
+// to them, within the router middlewares.
+func (s *ServerImpl) Group(prefix string, mw ...mux.MiddlewareFunc) *mux.Router {
+	group := s.Router.PathPrefix(prefix).Subrouter()
+	for _, m := range mw {
+		group.Use(m)
+	}
+	return group
+}
+
+// UpdateRouter replaces the router serving the requests with router without dropping connections. The requests in-flight
+// finish on the previous router. Unless served on the AdminPort or skipped, the built-in endpoints are registered again
+// on router, but the routes registered through the server, such as with Handle or RegisterStreamingRoute, are not.
+func (s *ServerImpl) UpdateRouter(router *mux.Router) {
+	s.routerMu.Lock()
+	defer s.routerMu.Unlock()
+	s.Router = router
+	s.rawRoutes = make(map[*mux.Route]bool)
+	if s.Configs.StrictSlash {
+		router.StrictSlash(true)
+	}
+	if s.adminRouter == nil && !s.Configs.SkipBuiltinEndpoints {
+		// The endpoint paths were already checked for duplicates by New.
+		s.registerBuiltinEndpoints(router)
+	}
+	s.prepareRouter(router)
+	s.routing.Store(&routing{router: router, rawRoutes: s.rawRoutes})
+}
+
+// prepareRouter installs the server middlewares and error handlers on router.
+func (s *ServerImpl) prepareRouter(router *mux.Router) {
+	router.Use(recordRoute)
+	if s.replayProtectedRoutes != nil {
+		router.Use(s.protectFromReplay)
+	}
+	if s.responseTransformers != nil {
+		router.Use(s.transformResponse)
+	}
+	if router.NotFoundHandler == nil && s.Configs.ProblemJSON {
+		router.NotFoundHandler = http.HandlerFunc(handleFuncProblemNotFound)
+	} else if router.NotFoundHandler == nil && s.Configs.JSONResponses {
+		router.NotFoundHandler = http.HandlerFunc(handleFuncNotFound)
+	}
+	if router.MethodNotAllowedHandler == nil {
+		router.MethodNotAllowedHandler = http.HandlerFunc(s.handleFuncMethodNotAllowed)
+	}
+}
+
+// Routes returns the routes with a path registered on the router serving the requests, in their matching order,
+// followed by the ones on the admin router when the AdminPort is set.
+func (s *ServerImpl) Routes() []RouteInfo {
+	var routes []RouteInfo
+	walk := func(router *mux.Router, admin bool) {
+		router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+			path, err := route.GetPathTemplate()
+			if err != nil {
+				return nil
+			}
+			methods, _ := route.GetMethods()
+			routes = append(routes, RouteInfo{Name: route.GetName(), Path: path, Methods: methods, Admin: admin})
+			return nil
+		})
+	}
+	walk(s.currentRouter(), false)
+	if s.adminRouter != nil {
+		walk(s.adminRouter, true)
+	}
+	return routes
+}
+
+// currentRouter returns the router serving the requests.
+func (s *ServerImpl) currentRouter() *mux.Router {
+	return s.routing.Load().router
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServerShouldServeRoutesRegisteredWithHandle(t *testing.T) {
+	configs := getTestConfigs()
+	var server Server = New(configs, mux.NewRouter())
+	server.Handle("PUT", "/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Item", mux.Vars(r)["id"])
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+
+	resp := doRequest(t, "PUT", configs.Port, "/items/1", nil)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected: 204; Got: %d", resp.StatusCode)
+	}
+	expectHeader(t, resp, "X-Item", "1")
+	testEndpoint(t, configs.Port, "/items/1", http.StatusMethodNotAllowed)
+}
+
+func TestServerShouldServeAllPathsBelowHandlePrefix(t *testing.T) {
+	configs := getTestConfigs()
+	var server Server = New(configs, mux.NewRouter())
+	server.HandlePrefix("/assets/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Path", r.URL.Path)
+	}))
+
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+
+	expectHeader(t, doRequest(t, "POST", configs.Port, "/assets/css/site.css", nil), "X-Path", "/assets/css/site.css")
+	testEndpoint(t, configs.Port, "/other", http.StatusNotFound)
+}
+
+func TestHandleWithMiddlewareShouldOnlyApplyTheMiddlewaresToItsRoute(t *testing.T) {
+	configs := getTestConfigs()
+	server := New(configs, mux.NewRouter())
+	cors := server.CORSMiddleware(&CORSConfig{AllowedOrigins: []string{allowedOrigin}})
+	tagged := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Middleware", "tagged")
+			next.ServeHTTP(w, r)
+		})
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	server.HandleWithMiddleware("GET", "/api/items", handler, cors, tagged)
+	server.Handle("GET", "/internal/items", handler)
+
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+
+	header := http.Header{"Origin": {allowedOrigin}}
+	resp := doRequest(t, "GET", configs.Port, "/api/items", header)
+	expectHeader(t, resp, "Access-Control-Allow-Origin", allowedOrigin)
+	expectHeader(t, resp, "X-Middleware", "tagged")
+	resp = doRequest(t, "GET", configs.Port, "/internal/items", header)
+	expectHeader(t, resp, "Access-Control-Allow-Origin", "")
+	expectHeader(t, resp, "X-Middleware", "")
+}
+
+func TestGroupShouldOnlyApplyTheMiddlewaresToItsRoutes(t *testing.T) {
+	configs := getTestConfigs()
+	server := New(configs, mux.NewRouter())
+	versioned := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-API-Version", "1")
+			next.ServeHTTP(w, r)
+		})
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	server.Group("/v1", versioned).Path("/items").Methods("GET").HandlerFunc(handler)
+	server.Handle("GET", "/items", handler)
+
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+
+	resp := doRequest(t, "GET", configs.Port, "/v1/items", nil)
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected: 200; Got: %d", resp.StatusCode)
+	}
+	expectHeader(t, resp, "X-API-Version", "1")
+	expectHeader(t, doRequest(t, "GET", configs.Port, "/items", nil), "X-API-Version", "")
+	expectHeader(t, doRequest(t, "GET", configs.Port, DefaultPingEndpoint, nil), "X-API-Version", "")
+}
+
+func TestServerWithStrictSlashShouldRedirectTheTrailingSlashPaths(t *testing.T) {
+	configs := getTestConfigs()
+	configs.StrictSlash = true
+	server := New(configs, mux.NewRouter())
+	server.Handle("GET", "/foo", func(w http.ResponseWriter, r *http.Request) {})
+
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(fmt.Sprintf("%s:%d/foo/", testServerEndpoint, configs.Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMovedPermanently {
+		t.Errorf("Expected: 301; Got: %d", resp.StatusCode)
+	}
+	expectHeader(t, resp, "Location", "/foo")
+	testEndpoint(t, configs.Port, "/foo/", 200)
+}
+
+func TestRoutesShouldListTheBuiltinAndUserRoutes(t *testing.T) {
+	configs := getTestConfigs()
+	server := New(configs, mux.NewRouter())
+	server.Handle("PUT", "/items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	routes := server.Routes()
+	expected := []RouteInfo{
+		{Name: DefaultPingEndpoint, Path: DefaultPingEndpoint, Methods: []string{"GET", "HEAD"}},
+		{Name: DefaultHealthcheckEndpoint, Path: DefaultHealthcheckEndpoint, Methods: []string{"GET", "HEAD"}},
+		{Name: DefaultShutdownEndpoint, Path: DefaultShutdownEndpoint, Methods: []string{"GET"}},
+		{Name: DefaultStartupEndpoint, Path: DefaultStartupEndpoint, Methods: []string{"GET"}},
+		{Name: DefaultReadinessEndpoint, Path: DefaultReadinessEndpoint, Methods: []string{"GET"}},
+		{Path: "/items/{id}", Methods: []string{"PUT"}},
+	}
+	if !reflect.DeepEqual(routes, expected) {
+		t.Errorf("Expected: %+v; Got: %+v", expected, routes)
+	}
+}
+
+func TestUpdateRouterShouldServeTheNewRoutesWhileTheInFlightRequestsFinish(t *testing.T) {
+	release := make(chan struct{})
+	router := mux.NewRouter()
+	router.Path(slowEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusAccepted)
+	})
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+	server := New(configs, router)
+
+	go server.Start()
+	defer server.Stop()
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+	testEndpoint(t, configs.Port, "/flagged", http.StatusNotFound)
+	slow := make(chan int)
+	go func() {
+		status, _ := getBody(t, configs.Port, slowEndpoint)
+		slow <- status
+	}()
+	for server.InFlight() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	updated := mux.NewRouter()
+	updated.Path("/flagged").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	server.UpdateRouter(updated)
+
+	testEndpoint(t, configs.Port, "/flagged", 200)
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+	testEndpoint(t, configs.Port, slowEndpoint, http.StatusNotFound)
+	close(release)
+	if status := <-slow; status != http.StatusAccepted {
+		t.Errorf("Expected: in-flight request to finish on the previous router with 202; Got: %d", status)
+	}
+}
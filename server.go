@@ -22,10 +22,21 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -54,71 +65,450 @@ const (
 	// DefaultShutdownEndpoint holds the default shutdown endpoint.
 	DefaultShutdownEndpoint = "/shutdown"
 
+	// DefaultStartupEndpoint holds the default startup endpoint.
+	DefaultStartupEndpoint = "/startup"
+
+	// DefaultReadinessEndpoint holds the default readiness endpoint.
+	DefaultReadinessEndpoint = "/readiness"
+
+	// DefaultDrainProgressInterval holds the default interval the in-flight requests are reported at while the server drains.
+	DefaultDrainProgressInterval = time.Second
+
+	// DefaultHandlerTimeoutMessage holds the response body sent when a handler doesn't finish within the HandlerTimeout.
+	DefaultHandlerTimeoutMessage = "handler timeout"
+
+	// DefaultUninterruptibleTimeout holds the default maximum time shutdown waits for uninterruptible requests to finish.
+	DefaultUninterruptibleTimeout = time.Minute
+
+	// DefaultLogTailEndpoint holds the endpoint streaming the access log when the log tail is enabled.
+	DefaultLogTailEndpoint = "/debug/logtail"
+
+	// DefaultLogTailSize holds the default number of access log lines kept in memory by the log tail.
+	DefaultLogTailSize = 1000
+
+	// DefaultResponseTransformerMaxSize holds the default maximum size in bytes of the responses buffered for transformation.
+	DefaultResponseTransformerMaxSize = 1 << 20
+
+	// DefaultEchoEndpoint holds the endpoint echoing the received requests when the echo endpoint is enabled.
+	DefaultEchoEndpoint = "/debug/echo"
+
+	// DefaultEchoMaxBodySize holds the default maximum size in bytes of the request bodies echoed back.
+	DefaultEchoMaxBodySize = 64 << 10
+
+	// DefaultNonceTTL holds the default time a nonce can't be reused for.
+	DefaultNonceTTL = 5 * time.Minute
+
+	// DefaultNonceCacheSize holds the default maximum number of nonces remembered for replay protection.
+	DefaultNonceCacheSize = 100000
+
+	// drainProgressBuffer holds the number of drain progress reports buffered for slow DrainProgress receivers.
+	drainProgressBuffer = 16
+
 	// stopSignal signals the Stop method was called and the server should stop.
 	stopSignal = syscall.Signal(0x99)
+
+	// cancelSignal signals the StartContext context was cancelled and the server should stop.
+	cancelSignal = syscall.Signal(0x98)
+
+	// drainSignal signals the Drain method was called and the server should drain.
+	drainSignal = syscall.Signal(0x97)
 )
 
+const (
+	// Graceful shuts down the server gracefully, respecting the DrainTimeout for all in-flight requests.
+	Graceful ShutdownBehavior = iota
+
+	// DumpAndExit dumps the stack traces of all goroutines and exits the process immediately.
+	DumpAndExit
+
+	// Immediate closes the server and all its connections immediately, without waiting for in-flight requests.
+	Immediate
+)
+
+var (
+	// ErrDuplicateEndpoint is returned by Start when several built-in endpoints are configured with the same path.
+	ErrDuplicateEndpoint = errors.New("server: duplicate endpoint")
+
+	// ErrProxyProtocolUntrusted is returned by Start when the PROXY protocol is enabled without trusted CIDRs.
+	ErrProxyProtocolUntrusted = errors.New("server: PROXY protocol enabled without trusted CIDRs")
+
+	// ErrTracerRequired is returned by Start when tracing is enabled without a Tracer.
+	ErrTracerRequired = errors.New("server: tracing enabled without a tracer")
+
+	// ErrReusePortUnsupported is returned by Start when EnableReusePort is set on a platform without SO_REUSEPORT.
+	ErrReusePortUnsupported = errors.New("server: SO_REUSEPORT not supported on this platform")
+
+	// ErrStoppedBeforeListening is returned by WaitReady when the server stops before listening.
+	ErrStoppedBeforeListening = errors.New("server: stopped before listening")
+
+	// exit terminates the process. Overridable in tests.
+	exit = os.Exit
+
+	// goroutineDumpOutput holds the writer goroutine stack traces are dumped to. Overridable in tests.
+	goroutineDumpOutput io.Writer = os.Stderr
+)
+
+// ShutdownBehavior represents how the server reacts to a received OS signal.
+type ShutdownBehavior int
+
 // ShutdownHandler is fired when the server should be shutdown.
 type ShutdownHandler = func(s *http.Server, ctx context.Context) error
 
+// PostDrainHook is fired after the server stopped serving requests.
+type PostDrainHook = func(ctx context.Context, cleanDrain bool) error
+
 // Configs holds server specific configs.
 // Port holds the server port.
+// BindAddress holds the IP address or host name the servers listen on, such as 127.0.0.1 or ::1. Empty listens on all
+// interfaces.
+// AdminPort holds the port the built-in endpoints are served on, apart from the router routes. Zero serves them on Port.
 // ShutdownTimeout holds the timeout to shutdown the server.
+// DrainTimeout holds the timeout for the in-flight requests to finish once shutdown begins. Defaults to ShutdownTimeout.
+// CleanupTimeout holds the timeout of the shutdown tasks, the tracked goroutines and the cleanups, counted from when
+// shutdown begins as tasks run along with the drain. Defaults to ShutdownTimeout.
 // ReadTimeout holds the read timeout.
 // WriteTimeout holds the write timeout.
-// PingEndpoint holds the ping endpoint.
-// HealthcheckEndpoint holds the healthcheck endpoint.
+// EnableReusePort sets the SO_REUSEPORT option on the listening socket, so multiple processes can listen on the same
+// port, such as during zero-downtime deploys. Only supported on Linux and the BSDs. Ignored with RegisterListener.
+// RetryAcceptErrors retries accepting connections with backoff on temporary and timeout accept errors instead of
+// shutting down, such as with custom listeners. The HTTP server already retries the errors reported as temporary.
+// DisableKeepAlives disables the HTTP keep-alives when the server starts. See SetKeepAlivesEnabled to toggle them later.
+// PingEndpoint holds the ping endpoint, served for GET and HEAD.
+// HealthcheckEndpoint holds the healthcheck endpoint, served for GET and HEAD.
 // ShutdownEndpoint holds the shutdown endpoint.
+// StartupEndpoint holds the startup endpoint, which replies 503 until RegisterStartupComplete is called.
+// ReadinessEndpoint holds the readiness endpoint, which replies 503 once shutdown begins.
+// PreShutdownDelay holds the time a graceful shutdown keeps serving requests after the readiness endpoint starts replying
+// 503, so load balancers stop sending new requests before the server drains.
+// SkipBuiltinEndpoints skips registering all built-in endpoints, leaving the router fully under the caller control.
+// DisablePingEndpoint skips registering the ping endpoint.
+// DisableHealthcheckEndpoint skips registering the healthcheck endpoint.
+// HealthCheckCacheTTL holds the time the results of the health checkers are reused for by the healthcheck endpoint
+// instead of running the checkers on every request. Zero means no caching.
+// HealthCheckTimeout holds the maximum time the health checkers have to run on each refresh of the healthcheck endpoint
+// results. Zero means no timeout.
+// DisableShutdownEndpoint skips registering the shutdown endpoint.
+// DrainProgressInterval holds the interval the in-flight requests are reported at while the server drains.
+// RecoverPanics recovers the panics of the handlers, logging them and replying 500 instead of aborting the response.
+// HandlerTimeout holds the maximum time handlers have to serve a request before the server replies 503. Zero means no timeout.
+// RequestTimeout enables applying the timeout sent by clients in a request header as the request context deadline when
+// set. Requests not served by then are replied with 504.
+// UninterruptibleTimeout holds the maximum time shutdown waits for uninterruptible requests to finish, regardless of ShutdownTimeout.
+// MaxRequestBodyBytes holds the maximum size in bytes of the request bodies. Larger bodies are replied with 413. Zero means no limit.
+// MaxRequestBodyExemptPaths holds the paths whose request bodies are not limited by MaxRequestBodyBytes.
+// MaxURLLength holds the maximum length of the request URIs. Longer URIs are replied with 414. Zero means no limit
+// besides the MaxHeaderBytes net/http applies to the request line and headers.
+// SlowRequestThreshold holds the latency above which requests are logged as slow warnings. Zero disables the slow request log.
+// Logger receives the errors logged by the HTTP servers, such as TLS handshake errors, and the server logs when set.
+// Defaults to the standard logger.
+// BaseContext optionally returns the base context for all incoming requests. The base context is cancelled when shutdown begins.
+// ConnContext optionally returns the context of the requests served on each new connection, derived from the base
+// context, such as to attach connection-scoped values. See http.Server.ConnContext.
+// EnableLogTail enables streaming the most recent access log lines as server-sent events on the log tail endpoint.
+// LogTailSize holds the maximum number of access log lines kept in memory by the log tail.
+// ResponseTransformerMaxSize holds the maximum size in bytes of the responses buffered for transformation.
+// EnableEchoEndpoint enables echoing the method, headers and body of the received requests as JSON on the echo endpoint.
+// EchoMaxBodySize holds the maximum size in bytes of the request bodies echoed back. Larger bodies are replied with 413.
+// EnableTracing starts a span with the Tracer for each request, named by the method and the matched route path template.
+// Tracer holds the tracer starting the request spans when EnableTracing is set.
+// EnableRequestID sets an ID on each request context and the X-Request-ID response header, reusing the inbound X-Request-ID.
+// Compression enables gzip-compressing the responses to the clients accepting it when set.
+// DecompressRequests transparently decompresses the request bodies sent with a gzip Content-Encoding. Malformed bodies
+// are replied with 400. The MaxRequestBodyBytes applies to the decompressed bodies.
+// DisableDirectoryListing replies 404 to the requests for static directories without an index.html instead of listing them.
+// StrictSlash redirects the requests whose path only differs from a route path by a trailing slash to the route path
+// with 301, such as /foo/ to /foo, instead of replying 404. It only applies to the routes registered after New,
+// including the built-in endpoints. As clients follow 301 redirects with GET, other methods should use the exact path.
+// JSONResponses replies the requests not matching any route with a JSON error body instead of plain text.
+// ProblemJSON replies the 400, 403, 404, 405, 409, 413, 414, 417, 429, 500, 503 and 504 errors of the server with RFC 7807
+// application/problem+json bodies. It takes precedence over JSONResponses.
+// CORS enables cross-origin resource sharing for all routes when set.
+// NonceTTL holds the time a nonce can't be reused for on replay protected routes.
+// NonceCacheSize holds the maximum number of nonces remembered for replay protection. The oldest nonces are forgotten first.
+// DisableContentSniffing sets the X-Content-Type-Options: nosniff header and a default content type on the responses without one.
+// DefaultHeaders holds the headers set on every response before the handlers run, such as security headers. Handlers
+// can override them.
+// MaxConcurrentRequests holds the maximum number of requests served at once, but the ones to the built-in endpoints.
+// Requests above it are replied with 503. Zero means no limit.
+// WorkerPoolSize holds the maximum number of requests served at once like MaxConcurrentRequests, but the requests
+// received while all workers are busy wait for one in a queue of WorkerQueueDepth requests. It can't be used along with
+// MaxConcurrentRequests. Zero means no pool.
+// WorkerQueueDepth holds the maximum number of requests waiting for a worker of the WorkerPoolSize. Requests received
+// while the queue is full are replied with 503. Zero means requests are replied with 503 while all workers are busy.
+// MaxStreamingConnections holds the maximum number of simultaneous streams acquired through AcquireStream. Zero means no limit.
+// RateLimit enables limiting the rate of requests of each client when set. Clients exceeding it are replied with 429.
+// AllowedCIDRs holds the CIDR ranges allowed to connect to the server. Empty allows all clients not denied.
+// DeniedCIDRs holds the CIDR ranges denied from connecting to the server.
+// TrustedProxies holds the CIDR ranges of the proxies whose X-Forwarded-For and X-Real-IP headers are honored by the IP
+// filter, the rate limit and ClientIP.
+// EnableProxyProtocol reads the PROXY protocol v1 and v2 headers sent by the ProxyProtocolTrustedCIDRs, such as load
+// balancers, serving the connections with the client address they forward.
+// ProxyProtocolTrustedCIDRs holds the CIDR ranges of the sources whose PROXY protocol headers are honored.
+// CertFile holds the path of the TLS certificate file. When set along with KeyFile, the server serves HTTPS and reloads
+// both files on SIGHUP, on the platforms that have it.
+// KeyFile holds the path of the TLS private key file.
+// TLSNextProtos holds the protocols negotiated through ALPN, in order of preference. Defaults to DefaultTLSNextProtos.
+// Leaving out h2 disables HTTP/2.
+// TLSConfig holds the complete TLS config to serve HTTPS with when set, taking precedence over CertFile and KeyFile,
+// which are then neither loaded nor reloaded, and over TLSNextProtos, ClientCAFile and RequireClientCert.
+// Autocert enables serving HTTPS with the certificates provisioned on demand from Let's Encrypt for its Domains, or by
+// its ACME certificate manager, when set.
+// ClientCAFile holds the path of the PEM file with the CAs client certificates are verified against.
+// RequireClientCert rejects the TLS handshakes of clients without a certificate signed by a CA in ClientCAFile.
+// SignalActions maps the OS signals the server listens to into their shutdown behavior. Defaults to os.Interrupt shutting down gracefully.
 type Configs struct {
-	Port                int
-	ShutdownTimeout     time.Duration
-	ReadTimeout         time.Duration
-	WriteTimeout        time.Duration
-	PingEndpoint        string
-	HealthcheckEndpoint string
-	ShutdownEndpoint    string
+	Port                       int
+	BindAddress                string
+	AdminPort                  int
+	ShutdownTimeout            time.Duration
+	DrainTimeout               time.Duration
+	CleanupTimeout             time.Duration
+	UninterruptibleTimeout     time.Duration
+	DrainProgressInterval      time.Duration
+	RecoverPanics              bool
+	HandlerTimeout             time.Duration
+	RequestTimeout             *RequestTimeoutConfig
+	ReadTimeout                time.Duration
+	WriteTimeout               time.Duration
+	EnableReusePort            bool
+	RetryAcceptErrors          bool
+	DisableKeepAlives          bool
+	PingEndpoint               string
+	HealthcheckEndpoint        string
+	ShutdownEndpoint           string
+	StartupEndpoint            string
+	ReadinessEndpoint          string
+	PreShutdownDelay           time.Duration
+	SkipBuiltinEndpoints       bool
+	DisablePingEndpoint        bool
+	DisableHealthcheckEndpoint bool
+	HealthCheckCacheTTL        time.Duration
+	HealthCheckTimeout         time.Duration
+	DisableShutdownEndpoint    bool
+	MaxRequestBodyBytes        int64
+	MaxRequestBodyExemptPaths  []string
+	MaxURLLength               int
+	SlowRequestThreshold       time.Duration
+	Logger                     Logger
+	BaseContext                func(net.Listener) context.Context
+	ConnContext                func(ctx context.Context, c net.Conn) context.Context
+	EnableLogTail              bool
+	LogTailSize                int
+	ResponseTransformerMaxSize int
+	EnableEchoEndpoint         bool
+	EchoMaxBodySize            int64
+	EnableTracing              bool
+	Tracer                     Tracer
+	EnableRequestID            bool
+	Compression                *CompressionConfig
+	DecompressRequests         bool
+	DisableDirectoryListing    bool
+	StrictSlash                bool
+	JSONResponses              bool
+	ProblemJSON                bool
+	CORS                       *CORSConfig
+	NonceTTL                   time.Duration
+	NonceCacheSize             int
+	DisableContentSniffing     bool
+	DefaultHeaders             map[string]string
+	MaxConcurrentRequests      int
+	WorkerPoolSize             int
+	WorkerQueueDepth           int
+	MaxStreamingConnections    int
+	RateLimit                  *RateLimitConfig
+	AllowedCIDRs               []string
+	DeniedCIDRs                []string
+	TrustedProxies             []string
+	EnableProxyProtocol        bool
+	ProxyProtocolTrustedCIDRs  []string
+	CertFile                   string
+	KeyFile                    string
+	TLSNextProtos              []string
+	TLSConfig                  *tls.Config
+	Autocert                   *AutocertConfig
+	ClientCAFile               string
+	RequireClientCert          bool
+	SignalActions              map[os.Signal]ShutdownBehavior
 }
 
 // Server represents a HTTP server.
 type Server interface {
 	Start() error
+	StartContext(ctx context.Context) error
 	Stop() error
+	Drain(ctx context.Context) error
+	Done() <-chan struct{}
+	WaitReady(ctx context.Context) error
 	GetHTTPServer() *http.Server
 	RegisterOnShutdown(f func())
 	RegisterServerStartHandler(f func(s *http.Server) error)
+	RegisterPreStartHandler(f func() error)
+	RegisterPingHandler(handler func(w http.ResponseWriter, r *http.Request))
 	RegisterHealthcheckEndpoint(path string, handler func(w http.ResponseWriter, r *http.Request))
+	RegisterHealthChecker(name string, criticality HealthCriticality, check func(ctx context.Context) error)
 	RegisterServerShutdownHandler(f ShutdownHandler)
+	RegisterListener(l net.Listener)
+	RegisterOnListening(f func(addr net.Addr))
+	RegisterConnStateHandler(f func(c net.Conn, state http.ConnState))
+	RegisterResponseTransformer(routeName string, transform ResponseTransformer)
+	RegisterDrainProgressHandler(f func(inFlight int))
+	DrainProgress() <-chan int
+	RegisterReplayProtection(routeName string)
+	RegisterPostDrainHook(f PostDrainHook)
+	RegisterStartupComplete()
+	RegisterShutdownPhase(name string, before []string, fn func(ctx context.Context) error) error
+	RegisterShutdownTask(f func(ctx context.Context) error)
+	RegisterCleanup(f func(ctx context.Context) error)
+	RegisterShutdownFlusher(f func(ctx context.Context) error)
+	RegisterRejectedRequestHandler(f func(r *http.Request))
+	RegisterExpectContinueHandler(f func(r *http.Request) bool)
+	RegisterInternalErrorHandler(f func(w http.ResponseWriter, r *http.Request, recovered interface{}))
+	RegisterDebugCapture(pathPrefix string, maxBytes int)
+	WithContextValue(key, value interface{})
+	RegisterRawHandler(method, path string, handler http.HandlerFunc)
+	UpdateRouter(router *mux.Router)
+	TrackGoroutine() (done func())
+	Handle(method, path string, handler http.HandlerFunc)
+	HandlePrefix(prefix string, handler http.Handler)
+	HandleWithMiddleware(method, path string, handler http.HandlerFunc, mw ...mux.MiddlewareFunc)
+	Group(prefix string, mw ...mux.MiddlewareFunc) *mux.Router
+	Routes() []RouteInfo
+	CORSMiddleware(c *CORSConfig) mux.MiddlewareFunc
+	RegisterStaticDir(urlPrefix, fsPath string)
+	RegisterStaticFS(urlPrefix string, fsys fs.FS)
+	RegisterOpenAPISpec(path string, spec []byte, contentType string)
+	RegisterStreamingRoute(path string, handler func(w http.ResponseWriter, r *http.Request))
+	RegisterNotFoundHandler(handler http.Handler)
+	RegisterMethodNotAllowedHandler(handler http.Handler)
+	InFlight() int
+	Stats() Stats
+	SetKeepAlivesEnabled(enabled bool)
+	CloseIdleConnections()
+	SetMaintenanceMode(on bool, retryAfter time.Duration)
+	Pause()
+	Resume()
+}
+
+// StartError is returned by Start when the server fails to listen or serve on Addr.
+type StartError struct {
+	Addr string
+	Err  error
+}
+
+func (e *StartError) Error() string {
+	return fmt.Sprintf("server: start on %s: %s", e.Addr, e.Err.Error())
+}
+
+// Unwrap returns the underlying listen or serve error.
+func (e *StartError) Unwrap() error {
+	return e.Err
 }
 
 // ServerImpl implements a HTTP Server.
 type ServerImpl struct {
-	Configs               *Configs
-	Router                *mux.Router
-	HTTPServer            *http.Server
-	healthcheckHandler    func(w http.ResponseWriter, r *http.Request)
-	serverStartHandler    func(s *http.Server) error
-	serverShutdownHandler ShutdownHandler
-	stop                  chan os.Signal
-	stopError             chan error
-	pingEndpoint          string
-	healthcheckEndpoint   string
-	shutdownEndpoint      string
+	Configs                *Configs
+	Router                 *mux.Router
+	HTTPServer             *http.Server
+	adminRouter            *mux.Router
+	adminServer            *http.Server
+	challengeServer        *http.Server
+	certificateManager     CertificateManager
+	pingHandler            func(w http.ResponseWriter, r *http.Request)
+	healthcheckHandler     func(w http.ResponseWriter, r *http.Request)
+	healthCheckers         []healthChecker
+	healthCache            *healthCache
+	serverStartHandler     func(s *http.Server) error
+	preStartHandler        func() error
+	serverShutdownHandlers []ShutdownHandler
+	connStateHandler       func(c net.Conn, state http.ConnState)
+	onShutdown             []func()
+	onListening            func(addr net.Addr)
+	drainProgressHandler   func(inFlight int)
+	drainProgress          drainReports
+	postDrainHook          PostDrainHook
+	shutdownPhases         []*shutdownPhase
+	shutdownTasks          []func(ctx context.Context) error
+	cleanups               []func(ctx context.Context) error
+	flushers               []func(ctx context.Context) error
+	rejectedRequestHandler func(r *http.Request)
+	expectContinueHandler  func(r *http.Request) bool
+	internalErrorHandler   func(w http.ResponseWriter, r *http.Request, recovered interface{})
+	debugCaptures          []debugCapture
+	contextValues          []contextValue
+	listener               net.Listener
+	logTail                *logTail
+	responseTransformers   map[string]ResponseTransformer
+	replayProtectedRoutes  map[string]bool
+	nonces                 *nonceCache
+	timeoutExemptPaths     map[string]bool
+	rawRoutes              map[*mux.Route]bool
+	routing                atomic.Pointer[routing]
+	routerMu               sync.Mutex
+	ipFilter               *ipFilter
+	trustedProxies         []*net.IPNet
+	rateLimiter            *rateLimiter
+	proxyProtocolTrusted   []*net.IPNet
+	certificates           *certificateReloader
+	serveTLS               bool
+	streams                chan struct{}
+	concurrencyLimiter     *concurrencyLimiter
+	configsError           error
+	conns                  connTracker
+	inFlight               requestGroup
+	totalRequests          atomic.Uint64
+	startedAt              atomic.Pointer[time.Time]
+	uninterruptible        requestGroup
+	goroutines             requestGroup
+	stop                   chan os.Signal
+	shuttingDown           chan struct{}
+	stopError              chan error
+	drainContext           context.Context
+	stopMu                 sync.Mutex
+	stopped                bool
+	stopResult             error
+	listening              broadcast
+	done                   broadcast
+	pingEndpoint           string
+	healthcheckEndpoint    string
+	shutdownEndpoint       string
+	startupEndpoint        string
+	startupComplete        int32
+	readinessEndpoint      string
+	notReady               int32
+	started                int32
+	maintenance            int32
+	maintenanceRetryAfter  int32
+	paused                 int32
 }
 
 // NewConfigs initializes a new instance of Configs with default values.
 func NewConfigs() *Configs {
 	return &Configs{
-		Port:                DefaultPort,
-		ShutdownTimeout:     DefaultShutdownTimeout,
-		ReadTimeout:         DefaultReadTimeout,
-		WriteTimeout:        DefaultWriteTimeout,
-		PingEndpoint:        DefaultPingEndpoint,
-		HealthcheckEndpoint: DefaultHealthcheckEndpoint,
-		ShutdownEndpoint:    DefaultShutdownEndpoint,
+		Port:                       DefaultPort,
+		ShutdownTimeout:            DefaultShutdownTimeout,
+		UninterruptibleTimeout:     DefaultUninterruptibleTimeout,
+		DrainProgressInterval:      DefaultDrainProgressInterval,
+		ReadTimeout:                DefaultReadTimeout,
+		WriteTimeout:               DefaultWriteTimeout,
+		PingEndpoint:               DefaultPingEndpoint,
+		HealthcheckEndpoint:        DefaultHealthcheckEndpoint,
+		ShutdownEndpoint:           DefaultShutdownEndpoint,
+		StartupEndpoint:            DefaultStartupEndpoint,
+		ReadinessEndpoint:          DefaultReadinessEndpoint,
+		LogTailSize:                DefaultLogTailSize,
+		ResponseTransformerMaxSize: DefaultResponseTransformerMaxSize,
+		EchoMaxBodySize:            DefaultEchoMaxBodySize,
+		NonceTTL:                   DefaultNonceTTL,
+		NonceCacheSize:             DefaultNonceCacheSize,
 	}
 }
 
 // New initializes a new instance of Server.
+// The built-in endpoints are registered after the routes already in router, always in the same order: ping,
+// healthcheck, shutdown, startup, readiness, log tail and echo. Routes registered before New take precedence over the
+// built-in endpoints on the same path. The built-in endpoints are raw routes, not wrapped by the router middlewares, so
+// they stay reachable whatever middlewares are added to router, such as authentication ones.
+// Set SkipBuiltinEndpoints to register none of them.
 func New(configs *Configs, router *mux.Router) Server {
 	server := &ServerImpl{
 		Configs:             configs,
@@ -127,7 +517,35 @@ func New(configs *Configs, router *mux.Router) Server {
 		pingEndpoint:        configs.PingEndpoint,
 		healthcheckEndpoint: configs.HealthcheckEndpoint,
 		shutdownEndpoint:    configs.ShutdownEndpoint,
+		startupEndpoint:     configs.StartupEndpoint,
+		readinessEndpoint:   configs.ReadinessEndpoint,
+		rawRoutes:           make(map[*mux.Route]bool),
+	}
+	server.HTTPServer.BaseContext = server.baseContext
+	if configs.StrictSlash {
+		// mux only applies StrictSlash to the routes registered afterwards.
+		router.StrictSlash(true)
+	}
+	server.ipFilter, server.configsError = newIPFilter(configs)
+	if trustedProxies, err := parseCIDRs(configs.TrustedProxies); err != nil {
+		server.configsError = err
+	} else {
+		server.trustedProxies = trustedProxies
+	}
+	if configs.HealthCheckCacheTTL > 0 {
+		server.healthCache = &healthCache{ttl: configs.HealthCheckCacheTTL}
 	}
+	if configs.MaxConcurrentRequests > 0 && configs.WorkerPoolSize > 0 {
+		server.configsError = errors.New("server: MaxConcurrentRequests and WorkerPoolSize can't be used together")
+	} else if configs.MaxConcurrentRequests > 0 {
+		server.concurrencyLimiter = newConcurrencyLimiter(configs.MaxConcurrentRequests, 0)
+	} else if configs.WorkerPoolSize > 0 {
+		server.concurrencyLimiter = newConcurrencyLimiter(configs.WorkerPoolSize, configs.WorkerQueueDepth)
+	}
+	if configs.MaxStreamingConnections > 0 {
+		server.streams = make(chan struct{}, configs.MaxStreamingConnections)
+	}
+	server.HTTPServer.ConnState = server.connState
 	if server.pingEndpoint == "" {
 		server.pingEndpoint = DefaultPingEndpoint
 	}
@@ -137,23 +555,129 @@ func New(configs *Configs, router *mux.Router) Server {
 	if server.shutdownEndpoint == "" {
 		server.shutdownEndpoint = DefaultShutdownEndpoint
 	}
+	if server.startupEndpoint == "" {
+		server.startupEndpoint = DefaultStartupEndpoint
+	}
+	if server.readinessEndpoint == "" {
+		server.readinessEndpoint = DefaultReadinessEndpoint
+	}
 
-	router.Path(server.pingEndpoint).Name(server.pingEndpoint).Methods("GET").HandlerFunc(server.handleFuncPing)
-	router.Path(server.healthcheckEndpoint).Name(server.healthcheckEndpoint).Methods("GET").HandlerFunc(server.handleFuncHealthcheck)
-	router.Path(server.shutdownEndpoint).Name(server.shutdownEndpoint).Methods("GET").HandlerFunc(server.handleFuncShutdown)
+	endpoints := router
+	if configs.AdminPort != 0 {
+		server.adminRouter = mux.NewRouter()
+		server.adminServer = newAdminHTTPServer(configs, server.adminRouter)
+		endpoints = server.adminRouter
+	}
+	server.timeoutExemptPaths = map[string]bool{server.shutdownEndpoint: true}
+	if configs.EnableLogTail {
+		server.logTail = newLogTail(configs.LogTailSize)
+		server.timeoutExemptPaths[DefaultLogTailEndpoint] = true
+	}
+	if !configs.SkipBuiltinEndpoints {
+		if err := server.registerBuiltinEndpoints(endpoints); err != nil {
+			server.configsError = err
+		}
+	}
+	if configs.Compression != nil {
+		if err := configs.Compression.validate(); err != nil {
+			server.configsError = err
+		}
+	}
+	if configs.Autocert != nil {
+		if err := configs.Autocert.validate(configs); err != nil {
+			server.configsError = err
+		} else {
+			server.certificateManager = configs.Autocert.certificateManager()
+			if configs.Autocert.ChallengePort >= 0 {
+				server.challengeServer = newChallengeHTTPServer(configs, server.certificateManager)
+			}
+		}
+	}
+	if configs.RateLimit != nil {
+		var err error
+		if server.rateLimiter, err = newRateLimiter(configs); err != nil {
+			server.configsError = err
+		}
+	}
+	if configs.EnableProxyProtocol {
+		var err error
+		if server.proxyProtocolTrusted, err = parseCIDRs(configs.ProxyProtocolTrustedCIDRs); err != nil {
+			server.configsError = err
+		} else if len(server.proxyProtocolTrusted) == 0 {
+			server.configsError = ErrProxyProtocolUntrusted
+		}
+	}
+	if configs.EnableTracing && configs.Tracer == nil {
+		server.configsError = ErrTracerRequired
+	}
+	if configs.EnableReusePort && reusePortControl == nil {
+		server.configsError = ErrReusePortUnsupported
+	}
+	server.prepareRouter(router)
+	server.routing.Store(&routing{router: router, rawRoutes: server.rawRoutes})
+	server.HTTPServer.Handler = server.handler()
 
 	return server
 }
 
+// registerBuiltinEndpoints registers the enabled built-in endpoints on endpoints, in their documented order.
+func (s *ServerImpl) registerBuiltinEndpoints(endpoints *mux.Router) error {
+	paths := []string{s.startupEndpoint, s.readinessEndpoint}
+	if !s.Configs.DisablePingEndpoint {
+		paths = append(paths, s.pingEndpoint)
+		s.rawRoute(endpoints.Path(s.pingEndpoint).Name(s.pingEndpoint).Methods("GET", "HEAD").HandlerFunc(s.handleFuncPing))
+	}
+	if !s.Configs.DisableHealthcheckEndpoint {
+		paths = append(paths, s.healthcheckEndpoint)
+		s.rawRoute(endpoints.Path(s.healthcheckEndpoint).Name(s.healthcheckEndpoint).Methods("GET", "HEAD").HandlerFunc(s.handleFuncHealthcheck))
+	}
+	if !s.Configs.DisableShutdownEndpoint {
+		paths = append(paths, s.shutdownEndpoint)
+		s.rawRoute(endpoints.Path(s.shutdownEndpoint).Name(s.shutdownEndpoint).Methods("GET").HandlerFunc(s.handleFuncShutdown))
+	}
+	s.rawRoute(endpoints.Path(s.startupEndpoint).Name(s.startupEndpoint).Methods("GET").HandlerFunc(s.handleFuncStartup))
+	s.rawRoute(endpoints.Path(s.readinessEndpoint).Name(s.readinessEndpoint).Methods("GET").HandlerFunc(s.handleFuncReadiness))
+	if s.logTail != nil {
+		s.rawRoute(endpoints.Path(DefaultLogTailEndpoint).Name(DefaultLogTailEndpoint).Methods("GET").HandlerFunc(s.logTail.handleFuncLogTail))
+	}
+	if s.Configs.EnableEchoEndpoint {
+		s.rawRoute(endpoints.Path(DefaultEchoEndpoint).Name(DefaultEchoEndpoint).HandlerFunc(s.handleFuncEcho))
+	}
+	return checkDuplicateEndpoints(paths...)
+}
+
 // RegisterHealthcheckEndpoint register the handler to handle healthcheck responses.
+// If a route named path is already registered, such as the default healthcheck endpoint, its handler is replaced instead.
 func (s *ServerImpl) RegisterHealthcheckEndpoint(path string, handler func(w http.ResponseWriter, r *http.Request)) {
 	s.healthcheckEndpoint = path
 	s.healthcheckHandler = handler
-	s.Router.Path(path).Name(path).Methods("GET").HandlerFunc(s.handleFuncHealthcheck)
+	endpoints := s.Router
+	if s.adminRouter != nil {
+		endpoints = s.adminRouter
+	}
+	if route := endpoints.Get(path); route != nil {
+		route.HandlerFunc(s.handleFuncHealthcheck)
+		return
+	}
+	s.rawRoute(endpoints.Path(path).Name(path).Methods("GET", "HEAD").HandlerFunc(s.handleFuncHealthcheck))
+}
+
+// RegisterPingHandler registers the handler to handle ping responses, which default to 200 with an empty body.
+// The ping endpoint is registered if it was disabled or skipped.
+func (s *ServerImpl) RegisterPingHandler(handler func(w http.ResponseWriter, r *http.Request)) {
+	s.pingHandler = handler
+	endpoints := s.Router
+	if s.adminRouter != nil {
+		endpoints = s.adminRouter
+	}
+	if endpoints.Get(s.pingEndpoint) == nil {
+		s.rawRoute(endpoints.Path(s.pingEndpoint).Name(s.pingEndpoint).Methods("GET", "HEAD").HandlerFunc(s.handleFuncPing))
+	}
 }
 
 // RegisterOnShutdown registers a function to call on Shutdown. It delegates the calls to the standard http.Server package.
 func (s *ServerImpl) RegisterOnShutdown(f func()) {
+	s.onShutdown = append(s.onShutdown, f)
 	s.HTTPServer.RegisterOnShutdown(f)
 }
 
@@ -162,34 +686,246 @@ func (s *ServerImpl) RegisterServerStartHandler(f func(s *http.Server) error) {
 	s.serverStartHandler = f
 }
 
-// RegisterServerShutdownHandler registers a function that should shutdown the HTTP server.
+// RegisterPreStartHandler registers a function that runs before the server starts listening. If it returns an error,
+// Start returns the error without listening. Unlike the server start handler, it doesn't replace starting the HTTP server.
+func (s *ServerImpl) RegisterPreStartHandler(f func() error) {
+	s.preStartHandler = f
+}
+
+// RegisterServerShutdownHandler registers a function that should shutdown the HTTP server, replacing the HTTP server
+// Shutdown call. Multiple handlers run in registration order, all of them running even if some fail.
+// To run code once the server stopped accepting connections and drained instead, see RegisterCleanup.
 func (s *ServerImpl) RegisterServerShutdownHandler(f ShutdownHandler) {
-	s.serverShutdownHandler = f
+	s.serverShutdownHandlers = append(s.serverShutdownHandlers, f)
+}
+
+// RegisterDrainProgressHandler registers a function that receives the number of in-flight requests periodically while the server drains.
+func (s *ServerImpl) RegisterDrainProgressHandler(f func(inFlight int)) {
+	s.drainProgressHandler = f
+}
+
+// DrainProgress returns a channel receiving the number of in-flight requests at every DrainProgressInterval while the
+// server drains, followed by the number left once draining completes. The channel is closed when draining completes.
+// Receivers that can't keep up miss the oldest reports rather than delaying the drain.
+// Each Start call opens a new channel once the previous one is closed.
+func (s *ServerImpl) DrainProgress() <-chan int {
+	return s.drainProgress.channel()
+}
+
+// RegisterPostDrainHook registers a function that runs after the server stopped serving requests. cleanDrain reports whether
+// all in-flight requests finished, as opposed to the drain timing out or the connections being closed immediately.
+func (s *ServerImpl) RegisterPostDrainHook(f PostDrainHook) {
+	s.postDrainHook = f
+}
+
+// RegisterStartupComplete signals the application finished initializing, making the startup endpoint reply 200.
+func (s *ServerImpl) RegisterStartupComplete() {
+	atomic.StoreInt32(&s.startupComplete, 1)
+}
+
+// RegisterListener registers an already open listener the server should serve requests on instead of binding to the configured port.
+func (s *ServerImpl) RegisterListener(l net.Listener) {
+	s.listener = l
+}
+
+// RegisterOnListening registers a function that is called with the listener address once the server is bound and about
+// to serve requests. It isn't called when a custom server start handler is registered.
+func (s *ServerImpl) RegisterOnListening(f func(addr net.Addr)) {
+	s.onListening = f
+}
+
+// RegisterConnStateHandler registers a function that is called when a client connection changes state. See http.Server.ConnState.
+func (s *ServerImpl) RegisterConnStateHandler(f func(c net.Conn, state http.ConnState)) {
+	s.connStateHandler = f
 }
 
 // Start starts the server and blocks, listening for requests.
+// Start returns nil once the server shuts down, or the error that made it stop serving. The http.ErrServerClosed
+// returned by Serve on shutdown is never returned, even when returned, or wrapped, by a custom server start handler.
 func (s *ServerImpl) Start() error {
+	return s.StartContext(context.Background())
+}
+
+// StartContext starts the server and blocks, listening for requests until the server is stopped or drained, a shutdown
+// signal is received or ctx is done. When ctx is done the server shuts down gracefully, as with Stop, and nil is returned.
+// A server can be started again once StartContext returned, on new HTTP servers as net/http ones can't serve again once
+// shut down: the GetHTTPServer result changes accordingly and a listener registered with RegisterListener must be
+// registered again, as it's closed on shutdown.
+func (s *ServerImpl) StartContext(ctx context.Context) error {
+	s.listening.reset()
+	s.done.reset()
+	defer s.done.close()
+	if atomic.LoadInt32(&s.started) == 1 {
+		s.renewHTTPServers()
+	}
+	if s.configsError != nil {
+		return s.configsError
+	}
+	if s.preStartHandler != nil {
+		if err := s.preStartHandler(); err != nil {
+			return err
+		}
+	}
+	if s.Configs.TLSConfig != nil {
+		s.HTTPServer.TLSConfig = s.Configs.TLSConfig.Clone()
+		s.serveTLS = true
+	} else if s.Configs.CertFile != "" && s.Configs.KeyFile != "" {
+		certificates, err := newCertificateReloader(s.Configs.CertFile, s.Configs.KeyFile)
+		if err != nil {
+			return err
+		}
+		s.certificates = certificates
+		if s.HTTPServer.TLSConfig, err = s.newTLSConfig(certificates.getCertificate); err != nil {
+			return err
+		}
+		s.serveTLS = true
+
+		if reloadSignal != nil {
+			reload := make(chan os.Signal, 1)
+			signal.Notify(reload, reloadSignal)
+			defer signal.Stop(reload)
+			reloadDone := make(chan struct{})
+			defer close(reloadDone)
+			go s.reloadCertificateOnSignal(reload, reloadDone)
+		}
+	}
+	if s.Configs.Autocert != nil {
+		var err error
+		if s.HTTPServer.TLSConfig, err = s.newAutocertTLSConfig(); err != nil {
+			return err
+		}
+		s.serveTLS = true
+	}
+	if s.Configs.DisableKeepAlives {
+		s.HTTPServer.SetKeepAlivesEnabled(false)
+	}
+	startedAt := time.Now()
+	s.startedAt.Store(&startedAt)
+	if statsSignal != nil {
+		if _, ok := s.Configs.SignalActions[statsSignal]; !ok {
+			dump := make(chan os.Signal, 1)
+			signal.Notify(dump, statsSignal)
+			defer signal.Stop(dump)
+			dumpDone := make(chan struct{})
+			defer close(dumpDone)
+			go s.dumpStatsOnSignal(dump, dumpDone)
+		}
+	}
+	s.stopMu.Lock()
+	s.stopped, s.stopResult = false, nil
+	s.drainProgress.reset()
 	s.stop = make(chan os.Signal)
 	s.stopError = make(chan error)
-	signal.Notify(s.stop, os.Interrupt, stopSignal)
+	s.shuttingDown = make(chan struct{})
+	s.stopMu.Unlock()
+	atomic.StoreInt32(&s.started, 1)
+	signal.Notify(s.stop, s.signals()...)
+	defer signal.Stop(s.stop)
 	var serveError error
+	var serveErrorOnce sync.Once
+	// Force shutdown so this method can return with the first serve (original) error.
+	failServe := func(err error) {
+		serveErrorOnce.Do(func() {
+			serveError = err
+			s.stop <- os.Interrupt
+		})
+	}
 
 	go func() {
-		if err := s.startHTTPServer(); err != nil {
-			if err != http.ErrServerClosed {
-				// Force shutdown so this method can return with the serve (original) error.
-				serveError = err
-				s.stop <- os.Interrupt
+		if err := s.startHTTPServer(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			failServe(err)
+		}
+	}()
+	if s.adminServer != nil {
+		go func() {
+			if err := s.startAdminServer(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				failServe(err)
 			}
+		}()
+	}
+	if s.challengeServer != nil {
+		go func() {
+			if err := s.startChallengeServer(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				failServe(err)
+			}
+		}()
+	}
+
+	returned := make(chan struct{})
+	defer close(returned)
+	go func() {
+		select {
+		case <-ctx.Done():
+			select {
+			case s.stop <- cancelSignal:
+			case <-returned:
+			}
+		case <-returned:
 		}
 	}()
 
 	signal := <-s.stop
+	atomic.StoreInt32(&s.notReady, 1)
+	if signal == drainSignal {
+		close(s.shuttingDown)
+		_, err := s.drain(s.drainContext)
+		s.done.close()
+		if sideErr := s.stopSideServers(Graceful, s.drainContext); err == nil {
+			err = sideErr
+		}
+		s.stopError <- err
+		return nil
+	}
+	behavior := s.signalBehavior(signal, serveError)
+	if behavior == Graceful && serveError == nil && s.Configs.PreShutdownDelay > 0 {
+		time.Sleep(s.Configs.PreShutdownDelay)
+	}
+	close(s.shuttingDown)
+
+	if behavior == DumpAndExit {
+		pprof.Lookup("goroutine").WriteTo(goroutineDumpOutput, 2)
+		exit(1)
+		return nil
+	}
+	taskContext, cancelTasks := context.WithTimeout(context.Background(), s.cleanupTimeout())
+	defer cancelTasks()
+	waitShutdownTasks := s.startShutdownTasks(taskContext)
 
-	timeoutContext, cancel := context.WithTimeout(context.Background(), s.Configs.ShutdownTimeout)
+	var forced bool
+	var err error
+	if behavior == Immediate {
+		forced, err = true, s.HTTPServer.Close()
+		s.conns.closeHijacked()
+		s.drainProgress.close()
+	} else {
+		forced, err = s.drain(context.Background())
+	}
+	s.done.close()
+	if goroutinesErr := s.waitGoroutines(taskContext); err == nil {
+		err = goroutinesErr
+	}
+	if cleanupErr := s.runCleanups(taskContext); err == nil {
+		err = cleanupErr
+	}
+	if tasksErr := waitShutdownTasks(); err == nil {
+		err = tasksErr
+	}
+	hookContext, cancel := context.WithTimeout(context.Background(), s.Configs.ShutdownTimeout)
 	defer cancel()
+	cleanDrain := !forced
+	if phasesErr := s.runShutdownPhases(hookContext); err == nil {
+		err = phasesErr
+	}
+	if s.postDrainHook != nil {
+		if hookErr := s.postDrainHook(hookContext, cleanDrain); err == nil {
+			err = hookErr
+		}
+	}
+	s.runFlushers(hookContext)
 
-	err := s.shutdownHTTPServer(timeoutContext)
+	if sideErr := s.stopSideServers(behavior, hookContext); err == nil {
+		err = sideErr
+	}
 
 	// If Stop() was called, doesn't return any error here. Any errors after Stop() was called will be returned only in the Stop() method.
 	var origErr error
@@ -205,12 +941,155 @@ func (s *ServerImpl) Start() error {
 // Stop stops the server gracefully and synchronously, returning any error detected during shutdown.
 // The ShutdownTimeout is respected for all in-flight requests. When the server is no longer processing any requests,
 // Stop() will return and the server won't listen for requests anymore.
+// Stop is idempotent: calls while or after the server stops return the result of the first call, or nil if the
+// shutdown was initiated by a signal or a serve error.
 func (s *ServerImpl) Stop() error {
-	if s.stop != nil {
-		s.stop <- stopSignal
-		return <-s.stopError
+	s.stopMu.Lock()
+	defer s.stopMu.Unlock()
+	if s.stop == nil || s.stopped {
+		return s.stopResult
+	}
+	s.stopped = true
+	select {
+	case s.stop <- stopSignal:
+		s.stopResult = <-s.stopError
+	case <-s.shuttingDown:
+	}
+	return s.stopResult
+}
+
+// Drain stops accepting connections and waits for the in-flight requests to finish, as during a graceful shutdown but
+// also bounded by ctx, keeping the process alive, such as to run migrations before exiting. Unlike Stop, the shutdown
+// tasks, cleanups, phases, post-drain hook and flushers don't run. Start returns nil once drained, leaving the server stopped
+// but able to start again. Stop and Drain calls after a drain return its result, or nil if the server isn't running.
+func (s *ServerImpl) Drain(ctx context.Context) error {
+	s.stopMu.Lock()
+	defer s.stopMu.Unlock()
+	if s.stop == nil || s.stopped {
+		return s.stopResult
+	}
+	s.stopped = true
+	s.drainContext = ctx
+	select {
+	case s.stop <- drainSignal:
+		s.stopResult = <-s.stopError
+	case <-s.shuttingDown:
+	}
+	return s.stopResult
+}
+
+// Done returns a channel that is closed once the server stopped serving requests, after it drained or was drained with
+// Drain, before the cleanups run. The channel is also closed if Start fails. Each Start call opens a new channel.
+func (s *ServerImpl) Done() <-chan struct{} {
+	return s.done.wait()
+}
+
+// WaitReady blocks until the server listens for connections, failing with ctx.Err() if ctx is done first or with
+// ErrStoppedBeforeListening if the server stops first. With a server start handler it only returns once ctx is done or
+// the server stops, as the server doesn't know when the handler listens.
+func (s *ServerImpl) WaitReady(ctx context.Context) error {
+	select {
+	case <-s.listening.wait():
+		return nil
+	case <-s.done.wait():
+		return ErrStoppedBeforeListening
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// broadcast holds a channel closed once an event happens, reopened by reset for the next time the server starts.
+type broadcast struct {
+	mu sync.Mutex
+	c  chan struct{}
+}
+
+// wait returns the channel closed once the event happens.
+func (b *broadcast) wait() <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.c == nil {
+		b.c = make(chan struct{})
+	}
+	return b.c
+}
+
+// reset opens a new channel if the current one is closed.
+func (b *broadcast) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.c == nil || b.closed() {
+		b.c = make(chan struct{})
+	}
+}
+
+// close closes the channel if it's not closed yet.
+func (b *broadcast) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.c == nil {
+		b.c = make(chan struct{})
+	}
+	if !b.closed() {
+		close(b.c)
 	}
-	return nil
+}
+
+func (b *broadcast) closed() bool {
+	select {
+	case <-b.c:
+		return true
+	default:
+		return false
+	}
+}
+
+// drainReports holds the DrainProgress channel, reopened by reset for the next time the server starts.
+type drainReports struct {
+	mu     sync.Mutex
+	c      chan int
+	closed bool
+}
+
+// channel returns the channel receiving the reports of the current drain.
+func (d *drainReports) channel() chan int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.c == nil {
+		d.c = make(chan int, drainProgressBuffer)
+	}
+	return d.c
+}
+
+// reset opens a new channel if the current one is closed.
+func (d *drainReports) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.c == nil || d.closed {
+		d.c, d.closed = make(chan int, drainProgressBuffer), false
+	}
+}
+
+// close closes the channel if it's not closed yet.
+func (d *drainReports) close() {
+	c := d.channel()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.closed {
+		close(c)
+		d.closed = true
+	}
+}
+
+// SetKeepAlivesEnabled enables or disables the HTTP keep-alives while the server runs, such as to make clients reconnect
+// so they are rebalanced while shedding load.
+func (s *ServerImpl) SetKeepAlivesEnabled(enabled bool) {
+	s.HTTPServer.SetKeepAlivesEnabled(enabled)
+}
+
+// InFlight returns the number of requests currently being served.
+func (s *ServerImpl) InFlight() int {
+	return s.inFlight.len()
 }
 
 // GetHTTPServer returns the HTTP server instance,
@@ -218,18 +1097,310 @@ func (s *ServerImpl) GetHTTPServer() *http.Server {
 	return s.HTTPServer
 }
 
+// signals returns the OS signals the server should listen to.
+func (s *ServerImpl) signals() []os.Signal {
+	if len(s.Configs.SignalActions) == 0 {
+		return []os.Signal{os.Interrupt, stopSignal}
+	}
+	signals := []os.Signal{stopSignal}
+	for sig := range s.Configs.SignalActions {
+		signals = append(signals, sig)
+	}
+	return signals
+}
+
+// signalBehavior returns the shutdown behavior for the received signal. Stop() and serve errors always shutdown gracefully.
+func (s *ServerImpl) signalBehavior(sig os.Signal, serveError error) ShutdownBehavior {
+	if sig == stopSignal || sig == cancelSignal || sig == drainSignal || serveError != nil {
+		return Graceful
+	}
+	return s.Configs.SignalActions[sig]
+}
+
+// baseContext returns the base context for the requests served by l, cancelling it when shutdown begins.
+func (s *ServerImpl) baseContext(l net.Listener) context.Context {
+	ctx := context.Background()
+	if s.Configs.BaseContext != nil {
+		ctx = s.Configs.BaseContext(l)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-s.shuttingDown:
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+	return ctx
+}
+
+// connState is fired whenever a client connection changes state.
+func (s *ServerImpl) connState(c net.Conn, state http.ConnState) {
+	s.conns.track(c, state)
+	if s.connStateHandler != nil {
+		s.connStateHandler(c, state)
+	}
+}
+
+// drainTimeout returns the DrainTimeout, falling back to the ShutdownTimeout if it's not set.
+func (s *ServerImpl) drainTimeout() time.Duration {
+	if s.Configs.DrainTimeout > 0 {
+		return s.Configs.DrainTimeout
+	}
+	return s.Configs.ShutdownTimeout
+}
+
+// cleanupTimeout returns the CleanupTimeout, falling back to the ShutdownTimeout if it's not set.
+func (s *ServerImpl) cleanupTimeout() time.Duration {
+	if s.Configs.CleanupTimeout > 0 {
+		return s.Configs.CleanupTimeout
+	}
+	return s.Configs.ShutdownTimeout
+}
+
+// drain shuts down the HTTP server gracefully, waiting up to the drain timeout, or ctx, for the in-flight requests to finish.
+// If uninterruptible requests are still in-flight when the drain timeout expires, drain keeps waiting for them up to UninterruptibleTimeout.
+// The connections still open once the drain times out are closed, as are the hijacked connections, such as WebSocket
+// ones, if their handlers didn't close them by the drain timeout, as the HTTP server doesn't wait for them. forced
+// reports whether any connection was closed that way, in which case err is context.DeadlineExceeded unless shutting
+// down the HTTP server failed otherwise.
+func (s *ServerImpl) drain(ctx context.Context) (forced bool, err error) {
+	drainTimeout := s.drainTimeout()
+	hijackedDeadline := time.NewTimer(drainTimeout)
+	defer hijackedDeadline.Stop()
+	hardTimeout := s.Configs.UninterruptibleTimeout
+	if hardTimeout == 0 {
+		hardTimeout = DefaultUninterruptibleTimeout
+	}
+	if hardTimeout < drainTimeout {
+		hardTimeout = drainTimeout
+	}
+	hardContext, cancelHard := context.WithTimeout(ctx, hardTimeout)
+	defer cancelHard()
+	drainContext, cancelDrain := context.WithCancel(hardContext)
+	defer cancelDrain()
+
+	timer := time.AfterFunc(drainTimeout, func() {
+		select {
+		case <-s.uninterruptible.wait():
+		case <-hardContext.Done():
+		}
+		cancelDrain()
+	})
+	defer timer.Stop()
+
+	drained := make(chan struct{})
+	reported := make(chan struct{})
+	go s.reportDrainProgress(s.drainProgress.channel(), drained, reported)
+	defer func() {
+		close(drained)
+		<-reported
+	}()
+
+	err = s.shutdownHTTPServer(drainContext)
+	if err == nil {
+		// Uninterruptible handlers outliving their timeout keep running after their connections went idle.
+		select {
+		case <-s.uninterruptible.wait():
+		case <-hardContext.Done():
+		}
+	}
+	if drainContext.Err() != nil {
+		// The drain context is cancelled once the drain timeout expired and the uninterruptible requests finished, or
+		// at the UninterruptibleTimeout. Connections without in-flight requests are closed without cutting any.
+		if s.inFlight.len() > 0 || s.uninterruptible.len() > 0 {
+			forced, err = true, context.DeadlineExceeded
+		} else if errors.Is(err, drainContext.Err()) {
+			err = nil
+		}
+		s.HTTPServer.Close()
+	}
+	select {
+	case <-s.conns.hijackedGroup.wait():
+	case <-hijackedDeadline.C:
+	}
+	if s.conns.closeHijacked() > 0 {
+		forced = true
+		if err == nil {
+			err = context.DeadlineExceeded
+		}
+	}
+	return forced, err
+}
+
+// reportDrainProgress reports the number of in-flight requests to the drain progress handler and the DrainProgress channel
+// progress at every DrainProgressInterval until drained is closed, then closes the DrainProgress channel and reported.
+func (s *ServerImpl) reportDrainProgress(progress chan int, drained, reported chan struct{}) {
+	defer close(reported)
+	interval := s.Configs.DrainProgressInterval
+	if interval <= 0 {
+		interval = DefaultDrainProgressInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	report := func() {
+		inFlight := s.InFlight()
+		if s.drainProgressHandler != nil {
+			s.drainProgressHandler(inFlight)
+		}
+		publishDrainProgress(progress, inFlight)
+	}
+	report()
+	for {
+		select {
+		case <-ticker.C:
+			report()
+		case <-drained:
+			publishDrainProgress(progress, s.InFlight())
+			s.drainProgress.close()
+			return
+		}
+	}
+}
+
+// publishDrainProgress sends inFlight to progress, dropping the oldest report when the channel is full.
+func publishDrainProgress(progress chan int, inFlight int) {
+	for {
+		select {
+		case progress <- inFlight:
+			return
+		default:
+		}
+		select {
+		case <-progress:
+		default:
+		}
+	}
+}
+
+// logf logs through the HTTP server ErrorLog, falling back to the standard logger like net/http does.
+func (s *ServerImpl) logf(format string, args ...interface{}) {
+	if s.HTTPServer.ErrorLog != nil {
+		s.HTTPServer.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
 func (s *ServerImpl) startHTTPServer() error {
 	if s.serverStartHandler != nil {
 		return s.serverStartHandler(s.HTTPServer)
 	}
-	return s.HTTPServer.ListenAndServe()
+	l := s.listener
+	if l == nil {
+		var err error
+		if l, err = s.listen(s.HTTPServer.Addr); err != nil {
+			return &StartError{Addr: s.HTTPServer.Addr, Err: err}
+		}
+	}
+	addr := l.Addr().String()
+	if s.onListening != nil {
+		s.onListening(l.Addr())
+	}
+	s.listening.close()
+	if s.Configs.RetryAcceptErrors {
+		l = &retryListener{Listener: l, logf: s.logf}
+	}
+	if s.Configs.EnableProxyProtocol {
+		l = &proxyProtocolListener{Listener: l, trusted: s.proxyProtocolTrusted}
+	}
+	// Behind trusted proxies the client address is only known after parsing the request, or the PROXY protocol header,
+	// so filtering is left to the middleware.
+	if s.ipFilter != nil && len(s.ipFilter.trustedProxies) == 0 && !s.Configs.EnableProxyProtocol {
+		l = &filteredListener{Listener: l, filter: s.ipFilter}
+	}
+	var err error
+	if s.serveTLS {
+		err = s.HTTPServer.ServeTLS(l, "", "")
+	} else {
+		err = s.HTTPServer.Serve(l)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		return &StartError{Addr: addr, Err: err}
+	}
+	return err
 }
 
+// shutdownHTTPServer runs the registered shutdown handlers, or shuts down the HTTP server if none were registered.
+// The errors of multiple handlers are logged and joined, while the error of a single handler is returned as is.
 func (s *ServerImpl) shutdownHTTPServer(ctx context.Context) error {
-	if s.serverShutdownHandler != nil {
-		return s.serverShutdownHandler(s.HTTPServer, ctx)
+	switch len(s.serverShutdownHandlers) {
+	case 0:
+		return s.HTTPServer.Shutdown(ctx)
+	case 1:
+		return s.serverShutdownHandlers[0](s.HTTPServer, ctx)
+	}
+	var errs []error
+	for i, handler := range s.serverShutdownHandlers {
+		if err := handler(s.HTTPServer, ctx); err != nil {
+			s.logf("server: shutdown handler %d failed: %v", i, err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// stopSideServers stops the admin and challenge servers, if any, once the main server stopped.
+func (s *ServerImpl) stopSideServers(behavior ShutdownBehavior, ctx context.Context) error {
+	var err error
+	if s.adminServer != nil {
+		err = s.stopAdminServer(behavior, ctx)
+	}
+	if s.challengeServer != nil {
+		if challengeErr := s.stopChallengeServer(behavior, ctx); err == nil {
+			err = challengeErr
+		}
 	}
-	return s.HTTPServer.Shutdown(ctx)
+	return err
+}
+
+// renewHTTPServers replaces the HTTP servers shut down by the previous run with new ones holding the same settings.
+func (s *ServerImpl) renewHTTPServers() {
+	s.HTTPServer = renewHTTPServer(s.HTTPServer)
+	for _, f := range s.onShutdown {
+		s.HTTPServer.RegisterOnShutdown(f)
+	}
+	if s.adminServer != nil {
+		s.adminServer = renewHTTPServer(s.adminServer)
+	}
+	if s.challengeServer != nil {
+		s.challengeServer = renewHTTPServer(s.challengeServer)
+	}
+}
+
+// renewHTTPServer returns a new HTTP server holding the settings of server.
+func renewHTTPServer(server *http.Server) *http.Server {
+	return &http.Server{
+		Addr:                         server.Addr,
+		Handler:                      server.Handler,
+		DisableGeneralOptionsHandler: server.DisableGeneralOptionsHandler,
+		TLSConfig:                    server.TLSConfig,
+		ReadTimeout:                  server.ReadTimeout,
+		ReadHeaderTimeout:            server.ReadHeaderTimeout,
+		WriteTimeout:                 server.WriteTimeout,
+		IdleTimeout:                  server.IdleTimeout,
+		MaxHeaderBytes:               server.MaxHeaderBytes,
+		TLSNextProto:                 server.TLSNextProto,
+		ConnState:                    server.ConnState,
+		ErrorLog:                     server.ErrorLog,
+		BaseContext:                  server.BaseContext,
+		ConnContext:                  server.ConnContext,
+	}
+}
+
+// listen listens on addr, setting the SO_REUSEPORT option if EnableReusePort is set.
+func (s *ServerImpl) listen(addr string) (net.Listener, error) {
+	if !s.Configs.EnableReusePort {
+		return net.Listen("tcp", addr)
+	}
+	config := net.ListenConfig{Control: reusePortControl}
+	return config.Listen(context.Background(), "tcp", addr)
+}
+
+// listenAddress returns the address to listen on port, on the BindAddress or all interfaces if it's empty.
+func listenAddress(configs *Configs, port int) string {
+	return net.JoinHostPort(configs.BindAddress, strconv.Itoa(port))
 }
 
 func newHTTPServer(configs *Configs, router *mux.Router) *http.Server {
@@ -238,27 +1409,72 @@ func newHTTPServer(configs *Configs, router *mux.Router) *http.Server {
 		port = configs.Port
 	}
 	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
+		Addr:         listenAddress(configs, port),
 		Handler:      router,
 		WriteTimeout: configs.WriteTimeout,
 		ReadTimeout:  configs.ReadTimeout,
+		ErrorLog:     errorLog(configs.Logger),
+		ConnContext:  configs.ConnContext,
 	}
 	return server
 }
 
 func (s *ServerImpl) handleFuncPing(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(200)
+	if s.pingHandler != nil {
+		s.pingHandler(w, r)
+	} else {
+		w.WriteHeader(200)
+	}
 }
 
 func (s *ServerImpl) handleFuncHealthcheck(w http.ResponseWriter, r *http.Request) {
 	if s.healthcheckHandler != nil {
 		s.healthcheckHandler(w, r)
+	} else if len(s.healthCheckers) > 0 {
+		s.handleFuncHealthChecks(w, r)
 	} else {
 		w.WriteHeader(200)
 	}
 }
 
+func (s *ServerImpl) handleFuncStartup(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&s.startupComplete) == 1 {
+		w.WriteHeader(200)
+	} else {
+		w.WriteHeader(503)
+	}
+}
+
+func (s *ServerImpl) handleFuncReadiness(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&s.notReady) == 1 {
+		w.WriteHeader(503)
+	} else {
+		w.WriteHeader(200)
+	}
+}
+
+// handleFuncShutdown stops the server, replying 409 if it was not started, as Stop would then do nothing.
 func (s *ServerImpl) handleFuncShutdown(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&s.started) == 0 {
+		s.writeErrorDetail(w, http.StatusConflict, "server not started")
+		return
+	}
 	w.WriteHeader(200)
 	go s.Stop()
 }
+
+// checkDuplicateEndpoints returns ErrDuplicateEndpoint naming the paths shared by several of the endpoints.
+func checkDuplicateEndpoints(endpoints ...string) error {
+	seen := make(map[string]int)
+	var duplicates []string
+	for _, endpoint := range endpoints {
+		seen[endpoint]++
+		if seen[endpoint] == 2 {
+			duplicates = append(duplicates, endpoint)
+		}
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicateEndpoint, strings.Join(duplicates, ", "))
+	}
+	return nil
+}
@@ -24,19 +24,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
+type testContextKey string
+
 const (
 	maxRetries                = 3
 	testServerEndpoint        = "http://localhost"
 	customHealthcheckEndpoint = "/customhealthcheck"
+	slowEndpoint              = "/slow"
 )
 
 var (
@@ -52,6 +59,12 @@ func TestNewConfigsShouldReturnConfigsWithDefaultValuesSet(t *testing.T) {
 	if configs.ShutdownTimeout != DefaultShutdownTimeout {
 		t.Errorf("Expected: %d; Got: %d", DefaultShutdownTimeout, configs.ShutdownTimeout)
 	}
+	if configs.UninterruptibleTimeout != DefaultUninterruptibleTimeout {
+		t.Errorf("Expected: %d; Got: %d", DefaultUninterruptibleTimeout, configs.UninterruptibleTimeout)
+	}
+	if configs.DrainProgressInterval != DefaultDrainProgressInterval {
+		t.Errorf("Expected: %d; Got: %d", DefaultDrainProgressInterval, configs.DrainProgressInterval)
+	}
 	if configs.ReadTimeout != DefaultReadTimeout {
 		t.Errorf("Expected: %d; Got: %d", DefaultReadTimeout, configs.ReadTimeout)
 	}
@@ -67,6 +80,27 @@ func TestNewConfigsShouldReturnConfigsWithDefaultValuesSet(t *testing.T) {
 	if configs.ShutdownEndpoint != DefaultShutdownEndpoint {
 		t.Errorf("Expected: %s; Got: %s", DefaultShutdownEndpoint, configs.ShutdownEndpoint)
 	}
+	if configs.StartupEndpoint != DefaultStartupEndpoint {
+		t.Errorf("Expected: %s; Got: %s", DefaultStartupEndpoint, configs.StartupEndpoint)
+	}
+	if configs.ReadinessEndpoint != DefaultReadinessEndpoint {
+		t.Errorf("Expected: %s; Got: %s", DefaultReadinessEndpoint, configs.ReadinessEndpoint)
+	}
+	if configs.LogTailSize != DefaultLogTailSize {
+		t.Errorf("Expected: %d; Got: %d", DefaultLogTailSize, configs.LogTailSize)
+	}
+	if configs.ResponseTransformerMaxSize != DefaultResponseTransformerMaxSize {
+		t.Errorf("Expected: %d; Got: %d", DefaultResponseTransformerMaxSize, configs.ResponseTransformerMaxSize)
+	}
+	if configs.EchoMaxBodySize != DefaultEchoMaxBodySize {
+		t.Errorf("Expected: %d; Got: %d", DefaultEchoMaxBodySize, configs.EchoMaxBodySize)
+	}
+	if configs.NonceTTL != DefaultNonceTTL {
+		t.Errorf("Expected: %d; Got: %d", DefaultNonceTTL, configs.NonceTTL)
+	}
+	if configs.NonceCacheSize != DefaultNonceCacheSize {
+		t.Errorf("Expected: %d; Got: %d", DefaultNonceCacheSize, configs.NonceCacheSize)
+	}
 }
 
 func TestServerWithStartHandlerShouldStartServerSuccessfully(t *testing.T) {
@@ -82,6 +116,131 @@ func TestServerWithStartHandlerShouldStartServerSuccessfully(t *testing.T) {
 		nil)
 }
 
+func TestServerWithStartHandlerServingAListenerShouldReturnNilOnShutdown(t *testing.T) {
+	configs := getTestConfigs()
+	server := New(configs, mux.NewRouter())
+	server.RegisterServerStartHandler(func(s *http.Server) error {
+		l, err := net.Listen("tcp", s.Addr)
+		if err != nil {
+			return err
+		}
+		if err := s.Serve(l); err != nil {
+			return fmt.Errorf("serving %s: %w", l.Addr(), err)
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startError := make(chan error)
+	go func() {
+		startError <- server.StartContext(ctx)
+	}()
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+	cancel()
+
+	if err := <-startError; err != nil {
+		t.Errorf("Expected: success; Got: %s", err.Error())
+	}
+}
+
+func TestServerWithOnListeningHandlerShouldAcceptConnectionsOnceCalled(t *testing.T) {
+	configs := getTestConfigs()
+	server := New(configs, mux.NewRouter())
+	listening := make(chan net.Addr, 1)
+	server.RegisterOnListening(func(addr net.Addr) {
+		listening <- addr
+	})
+
+	go server.Start()
+	defer server.Stop()
+
+	addr := <-listening
+	resp, err := http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, addr.(*net.TCPAddr).Port, DefaultPingEndpoint))
+	if err != nil {
+		t.Fatalf("Expected: connection accepted; Got: %s", err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected: 200; Got: %d", resp.StatusCode)
+	}
+}
+
+func TestServerWithBindAddressShouldOnlyListenOnIt(t *testing.T) {
+	external := nonLoopbackIP(t)
+	configs := getTestConfigs()
+	configs.BindAddress = "127.0.0.1"
+	server := New(configs, mux.NewRouter())
+	listening := make(chan net.Addr, 1)
+	server.RegisterOnListening(func(addr net.Addr) {
+		listening <- addr
+	})
+
+	go server.Start()
+	defer server.Stop()
+
+	if addr := <-listening; !addr.(*net.TCPAddr).IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Expected: listening on 127.0.0.1; Got: %s", addr)
+	}
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+	if conn, err := net.Dial("tcp", net.JoinHostPort(external.String(), strconv.Itoa(configs.Port))); err == nil {
+		conn.Close()
+		t.Errorf("Expected: server unreachable on %s; Got: connection accepted", external)
+	}
+}
+
+// nonLoopbackIP returns an IP address of the host other than the loopback ones, skipping the test if there's none.
+func nonLoopbackIP(t *testing.T) net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return ipNet.IP
+		}
+	}
+	t.Skip("no non-loopback IPv4 address available")
+	return nil
+}
+
+func TestServerWithPreStartHandlerShouldRunItBeforeStarting(t *testing.T) {
+	preStartHit := false
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterPreStartHandler(func() error {
+				preStartHit = true
+				return nil
+			})
+		},
+		nil)
+
+	if !preStartHit {
+		t.Error("Expected: pre-start handler to be executed; Got: not executed")
+	}
+}
+
+func TestServerWithPreStartHandlerErrorShouldReturnErrorWithoutListening(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	testError := errors.New("Simulate pre-start error")
+	server := New(configs, router)
+	server.RegisterPreStartHandler(func() error {
+		return testError
+	})
+
+	if err := server.Start(); err != testError {
+		t.Errorf("Expected: pre-start test error; Got: %v", err)
+	}
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", configs.Port))
+	if err != nil {
+		t.Fatalf("Expected: port not bound; Got: %s", err.Error())
+	}
+	l.Close()
+}
+
 func TestServerWithShutdownHandlerShouldFireHandlersSuccessfully(t *testing.T) {
 	shutdownCount := 0
 	router := mux.NewRouter()
@@ -101,6 +260,34 @@ func TestServerWithShutdownHandlerShouldFireHandlersSuccessfully(t *testing.T) {
 	}
 }
 
+func TestRegisterConnStateHandlerShouldObserveConnectionStateTransitions(t *testing.T) {
+	var mu sync.Mutex
+	states := make(map[http.ConnState]bool)
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterConnStateHandler(func(c net.Conn, state http.ConnState) {
+				mu.Lock()
+				defer mu.Unlock()
+				states[state] = true
+			})
+		},
+		func(s Server) {
+			testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+		})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !states[http.StateNew] {
+		t.Error("Expected: StateNew observed; Got: not observed")
+	}
+	if !states[http.StateActive] {
+		t.Error("Expected: StateActive observed; Got: not observed")
+	}
+}
+
 func TestRegisterHealthcheckEndpointShouldRegisterAndStartEndpointsSuccessfully(t *testing.T) {
 	healthcheckHit := false
 	router := mux.NewRouter()
@@ -122,6 +309,93 @@ func TestRegisterHealthcheckEndpointShouldRegisterAndStartEndpointsSuccessfully(
 	}
 }
 
+func TestRegisterHealthcheckEndpointOnDefaultPathShouldReplaceTheExistingRoute(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterHealthcheckEndpoint(DefaultHealthcheckEndpoint, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+			})
+		},
+		func(s Server) {
+			testEndpoint(t, configs.Port, DefaultHealthcheckEndpoint, http.StatusAccepted)
+		})
+
+	routes := 0
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if template, _ := route.GetPathTemplate(); template == DefaultHealthcheckEndpoint {
+			routes++
+		}
+		return nil
+	})
+	if routes != 1 {
+		t.Errorf("Expected: 1 healthcheck route; Got: %d", routes)
+	}
+}
+
+func TestHealthcheckEndpointShouldReplyHEADWithTheGETStatusAndHeadersAndNoBody(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterHealthcheckEndpoint(DefaultHealthcheckEndpoint, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"status":"ok"}`))
+			})
+		},
+		func(s Server) {
+			resp, err := http.Head(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, DefaultHealthcheckEndpoint))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("Expected: 200; Got: %d", resp.StatusCode)
+			}
+			expectHeader(t, resp, "Content-Type", "application/json")
+			if body, err := ioutil.ReadAll(resp.Body); err != nil || len(body) > 0 {
+				t.Errorf("Expected: empty body; Got: %q, %v", body, err)
+			}
+			if resp := doRequest(t, "HEAD", configs.Port, DefaultPingEndpoint, nil); resp.StatusCode != http.StatusOK {
+				t.Errorf("Expected: 200 for HEAD on the ping endpoint; Got: %d", resp.StatusCode)
+			}
+		})
+}
+
+func TestRegisterPingHandlerShouldCustomizeThePingResponse(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterPingHandler(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("pong"))
+			})
+		},
+		func(s Server) {
+			if status, body := getBody(t, configs.Port, DefaultPingEndpoint); status != 200 || body != "pong" {
+				t.Errorf("Expected: 200 pong; Got: %d %q", status, body)
+			}
+		})
+}
+
+func TestRegisterPingHandlerWithDisabledPingEndpointShouldRegisterIt(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.DisablePingEndpoint = true
+	server := New(configs, router)
+	server.RegisterPingHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	if router.GetRoute(DefaultPingEndpoint) == nil {
+		t.Error("Expected: ping endpoint configured; Got: nil")
+	}
+}
+
 func TestNewServerShouldReturnServerWithEndpointsConfigured(t *testing.T) {
 	router := mux.NewRouter()
 	configs := getTestConfigs()
@@ -139,6 +413,141 @@ func TestNewServerShouldReturnServerWithEndpointsConfigured(t *testing.T) {
 	if router.GetRoute(DefaultShutdownEndpoint) == nil {
 		t.Error("Expected: shutdown endpoint configured; Got: nil")
 	}
+	if router.GetRoute(DefaultStartupEndpoint) == nil {
+		t.Error("Expected: startup endpoint configured; Got: nil")
+	}
+	if router.GetRoute(DefaultReadinessEndpoint) == nil {
+		t.Error("Expected: readiness endpoint configured; Got: nil")
+	}
+}
+
+func TestServerWithDisabledShutdownEndpointShouldReplyNotFoundOnIt(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.DisableShutdownEndpoint = true
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		testEndpoint(t, configs.Port, DefaultShutdownEndpoint, 404)
+		testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+		testEndpoint(t, configs.Port, DefaultHealthcheckEndpoint, 200)
+	})
+}
+
+func TestNewServerWithSkipBuiltinEndpointsShouldNotConfigureAnyOfThem(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.SkipBuiltinEndpoints = true
+	configs.EnableLogTail = true
+	configs.EnableEchoEndpoint = true
+	New(configs, router)
+
+	routes := 0
+	router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		routes++
+		return nil
+	})
+	if routes != 0 {
+		t.Errorf("Expected: no routes configured; Got: %d", routes)
+	}
+}
+
+func TestRouterMiddlewareAddedAfterNewShouldWrapTheUserRoutesOnly(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	server := New(configs, router)
+	router.Path("/items").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Middleware", "applied")
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	go server.Start()
+	defer server.Stop()
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+
+	expectHeader(t, doRequest(t, "GET", configs.Port, "/items", nil), "X-Middleware", "applied")
+	expectHeader(t, doRequest(t, "GET", configs.Port, DefaultPingEndpoint, nil), "X-Middleware", "")
+}
+
+func TestRouterMiddlewaresShouldNotWrapTheBuiltinEndpoints(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/private").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	configs := getTestConfigs()
+	server := New(configs, router)
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+	})
+
+	go server.Start()
+	defer server.Stop()
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+
+	testEndpoint(t, configs.Port, DefaultHealthcheckEndpoint, 200)
+	testEndpoint(t, configs.Port, "/private", http.StatusUnauthorized)
+}
+
+func TestNewServerShouldRegisterTheBuiltinEndpointsAfterTheExistingRoutes(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path(DefaultPingEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	configs := getTestConfigs()
+	configs.EnableEchoEndpoint = true
+	server := New(configs, router)
+
+	var paths []string
+	router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, _ := route.GetPathTemplate()
+		paths = append(paths, path)
+		return nil
+	})
+	expected := []string{DefaultPingEndpoint, DefaultPingEndpoint, DefaultHealthcheckEndpoint, DefaultShutdownEndpoint,
+		DefaultStartupEndpoint, DefaultReadinessEndpoint, DefaultEchoEndpoint}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected: %v; Got: %v", expected, paths)
+	}
+
+	go server.Start()
+	defer server.Stop()
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, http.StatusTeapot)
+}
+
+func TestNewServerWithDisabledEndpointsShouldNotConfigureThem(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.DisablePingEndpoint = true
+	configs.DisableHealthcheckEndpoint = true
+	configs.DisableShutdownEndpoint = true
+	New(configs, router)
+
+	if router.GetRoute(DefaultPingEndpoint) != nil {
+		t.Error("Expected: ping endpoint not configured; Got: configured")
+	}
+	if router.GetRoute(DefaultHealthcheckEndpoint) != nil {
+		t.Error("Expected: healthcheck endpoint not configured; Got: configured")
+	}
+	if router.GetRoute(DefaultShutdownEndpoint) != nil {
+		t.Error("Expected: shutdown endpoint not configured; Got: configured")
+	}
+}
+
+func TestServerWithDuplicateEndpointsShouldReturnErrorOnStart(t *testing.T) {
+	configs := getTestConfigs()
+	configs.PingEndpoint = "/status"
+	configs.HealthcheckEndpoint = "/status"
+	server := New(configs, mux.NewRouter())
+
+	err := server.Start()
+	if !errors.Is(err, ErrDuplicateEndpoint) {
+		t.Fatalf("Expected: %v; Got: %v", ErrDuplicateEndpoint, err)
+	}
+	if !strings.Contains(err.Error(), "/status") {
+		t.Errorf("Expected: error naming /status; Got: %s", err.Error())
+	}
 }
 
 func TestServerShouldStartAllPreConfiguredEndpointsSuccessfully(t *testing.T) {
@@ -152,6 +561,130 @@ func TestServerShouldStartAllPreConfiguredEndpointsSuccessfully(t *testing.T) {
 	})
 }
 
+func TestServerWithPreShutdownDelayShouldKeepServingWhileNotReady(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.PreShutdownDelay = 300 * time.Millisecond
+
+	runTestServer(t, configs, router, false, nil, func(s Server) {
+		testEndpoint(t, configs.Port, DefaultReadinessEndpoint, 200)
+
+		stopped := make(chan error)
+		go func() {
+			stopped <- s.Stop()
+		}()
+		time.Sleep(50 * time.Millisecond)
+		testEndpoint(t, configs.Port, DefaultReadinessEndpoint, 503)
+		testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+
+		if err := <-stopped; err != nil {
+			t.Errorf("Expected: success; Got: %s", err.Error())
+		}
+		testEndpoint(t, configs.Port, DefaultPingEndpoint, 404)
+	})
+}
+
+func TestStartupEndpointShouldReply503UntilStartupCompletes(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		testEndpoint(t, configs.Port, DefaultStartupEndpoint, 503)
+		s.RegisterStartupComplete()
+		testEndpoint(t, configs.Port, DefaultStartupEndpoint, 200)
+	})
+}
+
+func TestServerWithRegisteredListenerShouldServeRequestsThroughListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.Port = listener.Addr().(*net.TCPAddr).Port
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterListener(listener)
+		},
+		nil)
+}
+
+func TestServerWithBaseContextShouldPropagateContextValuesToRequests(t *testing.T) {
+	const key, value = testContextKey("key"), "value"
+	var got interface{}
+	router := mux.NewRouter()
+	router.Path("/context").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Context().Value(key)
+		w.WriteHeader(200)
+	})
+	configs := getTestConfigs()
+	configs.BaseContext = func(l net.Listener) context.Context {
+		return context.WithValue(context.Background(), key, value)
+	}
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		testEndpoint(t, configs.Port, "/context", 200)
+	})
+
+	if got != value {
+		t.Errorf("Expected: %s; Got: %v", value, got)
+	}
+}
+
+func TestServerWithConnContextShouldPropagateConnectionValuesToRequests(t *testing.T) {
+	const key = testContextKey("remoteAddr")
+	var got, remoteAddr interface{}
+	router := mux.NewRouter()
+	router.Path("/context").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, remoteAddr = r.Context().Value(key), r.RemoteAddr
+		w.WriteHeader(200)
+	})
+	configs := getTestConfigs()
+	configs.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+		return context.WithValue(ctx, key, c.RemoteAddr().String())
+	}
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		testEndpoint(t, configs.Port, "/context", 200)
+	})
+
+	if got == nil || got != remoteAddr {
+		t.Errorf("Expected: %v; Got: %v", remoteAddr, got)
+	}
+}
+
+func TestServerShouldCancelBaseContextWhenShutdownBegins(t *testing.T) {
+	requestStarted := make(chan struct{})
+	router := mux.NewRouter()
+	router.Path("/wait").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requestStarted)
+		<-r.Context().Done()
+		w.WriteHeader(200)
+	})
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+
+	runTestServer(t, configs, router, false, nil, func(s Server) {
+		go http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, "/wait"))
+		<-requestStarted
+
+		stopped := make(chan error)
+		go func() {
+			stopped <- s.Stop()
+		}()
+		select {
+		case err := <-stopped:
+			if err != nil {
+				t.Errorf("Expected: success; Got: %s", err.Error())
+			}
+		case <-time.After(time.Second):
+			t.Error("Expected: request context cancelled on shutdown; Got: shutdown blocked by request")
+		}
+	})
+}
+
 func TestServerWithStartErrorShouldReturnOriginalStartError(t *testing.T) {
 	configs := &Configs{
 		Port: -1,
@@ -160,20 +693,80 @@ func TestServerWithStartErrorShouldReturnOriginalStartError(t *testing.T) {
 	server := New(configs, router)
 
 	err := server.Start()
-	if err == nil {
-		t.Error("Expected: error as the server port is not valid; Got: success")
+	var startErr *StartError
+	if !errors.As(err, &startErr) {
+		t.Fatalf("Expected: StartError as the server port is not valid; Got: %v", err)
 	}
-	if operr, ok := err.(*net.OpError); ok {
-		if _, ok2 := operr.Err.(*net.AddrError); !ok2 {
-			t.Errorf("Expected: error as the server port is not valid; Got: %s", reflect.TypeOf(operr.Err))
-		}
-	} else {
-		t.Errorf("Expected: error as the server port is not valid; Got: %s", reflect.TypeOf(err))
+	if startErr.Addr != ":-1" {
+		t.Errorf("Expected: :-1; Got: %s", startErr.Addr)
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("Expected: net.OpError wrapped; Got: %s", reflect.TypeOf(startErr.Err))
+	}
+	if _, ok := opErr.Err.(*net.AddrError); !ok {
+		t.Errorf("Expected: error as the server port is not valid; Got: %s", reflect.TypeOf(opErr.Err))
 	}
 
 	testEndpoint(t, configs.Port, DefaultPingEndpoint, 404)
 }
 
+func TestServerStartedWithContextShouldStopWhenTheContextIsCancelled(t *testing.T) {
+	configs := getTestConfigs()
+	server := New(configs, mux.NewRouter())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	startError := make(chan error)
+	go func() {
+		startError <- server.StartContext(ctx)
+	}()
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+
+	cancel()
+	select {
+	case err := <-startError:
+		if err != nil {
+			t.Errorf("Expected: success; Got: %s", err.Error())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected: server stopped once the context is cancelled; Got: still running")
+	}
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 404)
+}
+
+func TestServerWithDisableKeepAlivesShouldCloseTheConnections(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.DisableKeepAlives = true
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		resp := doRequest(t, "GET", configs.Port, DefaultPingEndpoint, nil)
+		if !resp.Close {
+			t.Error("Expected: Connection: close; Got: keep-alive")
+		}
+	})
+}
+
+func TestSetKeepAlivesEnabledShouldToggleKeepAlivesWhileRunning(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		if resp := doRequest(t, "GET", configs.Port, DefaultPingEndpoint, nil); resp.Close {
+			t.Error("Expected: keep-alive; Got: Connection: close")
+		}
+		s.SetKeepAlivesEnabled(false)
+		if resp := doRequest(t, "GET", configs.Port, DefaultPingEndpoint, nil); !resp.Close {
+			t.Error("Expected: Connection: close; Got: keep-alive")
+		}
+		s.SetKeepAlivesEnabled(true)
+		if resp := doRequest(t, "GET", configs.Port, DefaultPingEndpoint, nil); resp.Close {
+			t.Error("Expected: keep-alive; Got: Connection: close")
+		}
+	})
+}
+
 func TestServerWithStopErrorShouldReturnOriginalStopError(t *testing.T) {
 	router := mux.NewRouter()
 	configs := getTestConfigs()
@@ -196,6 +789,149 @@ func TestServerWithStopErrorShouldReturnOriginalStopError(t *testing.T) {
 		})
 }
 
+func TestServerWithMultipleShutdownHandlersShouldRunAllAndJoinTheirErrors(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	testError := errors.New("Simulate shutdown error")
+	ran := 0
+
+	runTestServer(t, configs, router, false,
+		func(s Server) {
+			s.RegisterServerShutdownHandler(func(s *http.Server, ctx context.Context) error {
+				ran++
+				return testError
+			})
+			s.RegisterServerShutdownHandler(func(s *http.Server, ctx context.Context) error {
+				ran++
+				return s.Shutdown(ctx)
+			})
+		},
+		func(s Server) {
+			if err := s.Stop(); !errors.Is(err, testError) {
+				t.Errorf("Expected: joined error with the shutdown test error; Got: %v", err)
+			}
+		})
+
+	if ran != 2 {
+		t.Errorf("Expected: 2 shutdown handlers run; Got: %d", ran)
+	}
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 404)
+}
+
+func TestPostDrainHookShouldReceiveCleanDrainWhenAllRequestsFinished(t *testing.T) {
+	cleanDrain := false
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterPostDrainHook(func(ctx context.Context, clean bool) error {
+				cleanDrain = clean
+				return nil
+			})
+		},
+		nil)
+
+	if !cleanDrain {
+		t.Error("Expected: clean drain; Got: unclean drain")
+	}
+}
+
+func TestPostDrainHookShouldReceiveUncleanDrainWhenDrainTimedOut(t *testing.T) {
+	cleanDrain := true
+	requestStarted := make(chan struct{})
+	router := mux.NewRouter()
+	router.Path(slowEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requestStarted)
+		time.Sleep(200 * time.Millisecond)
+	})
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = 10 * time.Millisecond
+	configs.UninterruptibleTimeout = 10 * time.Millisecond
+
+	runTestServer(t, configs, router, false,
+		func(s Server) {
+			s.RegisterPostDrainHook(func(ctx context.Context, clean bool) error {
+				cleanDrain = clean
+				return nil
+			})
+		},
+		func(s Server) {
+			go http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, slowEndpoint))
+			<-requestStarted
+
+			if err := s.Stop(); err != context.DeadlineExceeded {
+				t.Errorf("Expected: %s; Got: %v", context.DeadlineExceeded, err)
+			}
+		})
+
+	if cleanDrain {
+		t.Error("Expected: unclean drain; Got: clean drain")
+	}
+}
+
+func TestPostDrainHookShouldReceiveCleanDrainWhenACleanupFailed(t *testing.T) {
+	cleanDrain := false
+	testError := errors.New("Simulate cleanup error")
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+
+	runTestServer(t, configs, router, false,
+		func(s Server) {
+			s.RegisterCleanup(func(ctx context.Context) error {
+				return testError
+			})
+			s.RegisterPostDrainHook(func(ctx context.Context, clean bool) error {
+				cleanDrain = clean
+				return nil
+			})
+		},
+		func(s Server) {
+			if err := s.Stop(); !errors.Is(err, testError) {
+				t.Errorf("Expected: %v; Got: %v", testError, err)
+			}
+		})
+
+	if !cleanDrain {
+		t.Error("Expected: clean drain; Got: unclean drain")
+	}
+}
+
+func TestPostDrainHookErrorShouldBeReturnedByStop(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	testError := errors.New("Simulate post drain hook error")
+
+	runTestServer(t, configs, router, false,
+		func(s Server) {
+			s.RegisterPostDrainHook(func(ctx context.Context, clean bool) error {
+				return testError
+			})
+		},
+		func(s Server) {
+			if err := s.Stop(); err != testError {
+				t.Errorf("Expected: post drain hook error; Got: %v", err)
+			}
+		})
+}
+
+func TestShutdownEndpointBeforeStartShouldReplyConflict(t *testing.T) {
+	configs := getTestConfigs()
+	server := New(configs, mux.NewRouter())
+	// Serve the server handler without starting the server.
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", configs.Port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notStarted := &http.Server{Handler: server.GetHTTPServer().Handler}
+	go notStarted.Serve(l)
+	defer notStarted.Close()
+
+	testEndpoint(t, configs.Port, DefaultShutdownEndpoint, http.StatusConflict)
+}
+
 func TestStopWithoutCallingStartShouldReturnNil(t *testing.T) {
 	router := mux.NewRouter()
 	configs := getTestConfigs()
@@ -206,6 +942,173 @@ func TestStopWithoutCallingStartShouldReturnNil(t *testing.T) {
 	}
 }
 
+func TestStopCalledConcurrentlyShouldReturnTheSameResultWithoutHanging(t *testing.T) {
+	testError := errors.New("Simulate post drain hook error")
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	server := New(configs, router)
+	server.RegisterPostDrainHook(func(ctx context.Context, clean bool) error {
+		return testError
+	})
+
+	go server.Start()
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+
+	stopped := make(chan error)
+	for i := 0; i < 5; i++ {
+		go func() {
+			stopped <- server.Stop()
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		select {
+		case err := <-stopped:
+			if err != testError {
+				t.Errorf("Expected: post drain hook error; Got: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected: all Stop calls to return; Got: Stop hung")
+		}
+	}
+}
+
+func TestStopAfterTheShutdownEndpointShouldNotHang(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	server := New(configs, router)
+	startError := make(chan error)
+	go func() {
+		startError <- server.Start()
+	}()
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+
+	testEndpoint(t, configs.Port, DefaultShutdownEndpoint, 200)
+	stopped := make(chan error)
+	go func() {
+		stopped <- server.Stop()
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected: Stop to return; Got: Stop hung")
+	}
+	if err := <-startError; err != nil {
+		t.Errorf("Expected: success; Got: %v", err)
+	}
+}
+
+func TestDrainShouldFinishTheInFlightRequestsAndLeaveTheServerAbleToStartAgain(t *testing.T) {
+	router := mux.NewRouter()
+	release := make(chan struct{})
+	router.Path(slowEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+	server := New(configs, router)
+	ctx, cancel := context.WithCancel(context.Background())
+	startError := make(chan error)
+	go func() {
+		startError <- server.StartContext(ctx)
+	}()
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+	slow := make(chan int)
+	go func() {
+		status, _ := getBody(t, configs.Port, slowEndpoint)
+		slow <- status
+	}()
+	for server.InFlight() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	drainProgress := server.DrainProgress()
+	drained := make(chan error)
+	go func() {
+		drained <- server.Drain(context.Background())
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	if err := <-drained; err != nil {
+		t.Errorf("Expected: nil; Got: %v", err)
+	}
+	if status := <-slow; status != 200 {
+		t.Errorf("Expected: in-flight request to finish with 200; Got: %d", status)
+	}
+	if _, err := http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, DefaultPingEndpoint)); err == nil {
+		t.Error("Expected: ping to stop responding; Got: success")
+	}
+	for range drainProgress {
+	}
+	if err := <-startError; err != nil {
+		t.Errorf("Expected: Start to return nil once drained; Got: %v", err)
+	}
+	if err := server.Stop(); err != nil {
+		t.Errorf("Expected: Stop to be a no-op; Got: %v", err)
+	}
+
+	go func() {
+		startError <- server.StartContext(ctx)
+	}()
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+	testEndpoint(t, configs.Port, slowEndpoint, 200)
+	cancel()
+	if err := <-startError; err != nil {
+		t.Errorf("Expected: success; Got: %v", err)
+	}
+}
+
+func TestDoneShouldBeClosedOnceTheServerStoppedServing(t *testing.T) {
+	configs := getTestConfigs()
+	server := New(configs, mux.NewRouter())
+	go server.Start()
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+	select {
+	case <-server.Done():
+		t.Fatal("Expected: Done open while serving; Got: closed")
+	default:
+	}
+
+	server.Stop()
+	select {
+	case <-server.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected: Done closed after Stop; Got: open")
+	}
+	if _, err := http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, DefaultPingEndpoint)); err == nil {
+		t.Error("Expected: server not serving once Done is closed; Got: success")
+	}
+}
+
+func TestWaitReadyShouldReturnOnceTheServerListens(t *testing.T) {
+	configs := getTestConfigs()
+	server := New(configs, mux.NewRouter())
+	go server.Start()
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.WaitReady(ctx); err != nil {
+		t.Fatalf("Expected: nil; Got: %v", err)
+	}
+	status, _ := getBody(t, configs.Port, DefaultPingEndpoint)
+	if status != 200 {
+		t.Errorf("Expected: 200; Got: %d", status)
+	}
+}
+
+func TestWaitReadyShouldFailWhenTheServerStopsBeforeListening(t *testing.T) {
+	configs := getTestConfigs()
+	configs.EnableTracing = true
+	server := New(configs, mux.NewRouter())
+	go server.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.WaitReady(ctx); err != ErrStoppedBeforeListening {
+		t.Errorf("Expected: ErrStoppedBeforeListening; Got: %v", err)
+	}
+}
+
 func TestGetHTTPServerShouldReturnInitializedServer(t *testing.T) {
 	router := mux.NewRouter()
 	configs := getTestConfigs()
@@ -273,9 +1176,69 @@ func testEndpoint(t *testing.T, port int, path string, expectedStatusCode int) {
 	}
 }
 
+// waitForListener waits until the server accepts TCP connections on port.
+func waitForListener(t *testing.T, port int) {
+	for attempt := 1; ; attempt++ {
+		conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+		if err == nil {
+			conn.Close()
+			return
+		}
+		if attempt == maxRetries {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// getBody issues a GET request to path, returning the response status code and body.
+func getBody(t *testing.T, port int, path string) (int, string) {
+	resp, err := http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, port, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// doRequest issues a request with the given method and headers to path, returning the response.
+func doRequest(t *testing.T, method string, port int, path string, header http.Header) *http.Response {
+	req, err := http.NewRequest(method, fmt.Sprintf("%s:%d%s", testServerEndpoint, port, path), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// expectHeader asserts the response header key holds the expected value.
+func expectHeader(t *testing.T, resp *http.Response, key, expected string) {
+	if got := resp.Header.Get(key); got != expected {
+		t.Errorf("Expected: %s header %q; Got: %q", key, expected, got)
+	}
+}
+
 // Increment and return a new port for each test, avoiding port collisions on parallel tests.
+// Ports already bound by other processes are skipped.
 func getTestConfigs() *Configs {
-	testServerPort++
+	for {
+		testServerPort++
+		if l, err := net.Listen("tcp", fmt.Sprintf(":%d", testServerPort)); err == nil {
+			l.Close()
+			break
+		}
+	}
 
 	return &Configs{
 		Port: testServerPort,
@@ -0,0 +1,203 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+//go:build unix
+
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServerWithGracefulSignalActionShouldFinishInFlightRequests(t *testing.T) {
+	configs := getSignalTestConfigs()
+
+	resp, err := sendSignalDuringSlowRequest(t, configs, syscall.SIGTERM)
+	if err != nil {
+		t.Fatalf("Expected: in-flight request to finish; Got: %s", err.Error())
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected: 200; Got: %d", resp.StatusCode)
+	}
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 404)
+}
+
+func TestServerWithImmediateSignalActionShouldCloseInFlightRequests(t *testing.T) {
+	configs := getSignalTestConfigs()
+
+	if _, err := sendSignalDuringSlowRequest(t, configs, syscall.SIGUSR2); err == nil {
+		t.Error("Expected: in-flight request to be closed; Got: success")
+	}
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 404)
+}
+
+func TestServerWithDumpAndExitSignalActionShouldDumpGoroutinesAndExit(t *testing.T) {
+	exitCode := -1
+	dump := &bytes.Buffer{}
+	exit = func(code int) {
+		exitCode = code
+	}
+	goroutineDumpOutput = dump
+	defer func() {
+		exit = os.Exit
+		goroutineDumpOutput = os.Stderr
+	}()
+
+	configs := getSignalTestConfigs()
+	server := New(configs, mux.NewRouter())
+	startError := make(chan error)
+	go func() {
+		startError <- server.Start()
+	}()
+	defer server.GetHTTPServer().Close()
+
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+	syscall.Kill(os.Getpid(), syscall.SIGQUIT)
+
+	if err := <-startError; err != nil {
+		t.Errorf("Expected: success; Got: %s", err.Error())
+	}
+	if exitCode != 1 {
+		t.Errorf("Expected: exit code 1; Got: %d", exitCode)
+	}
+	if !strings.Contains(dump.String(), "goroutine") {
+		t.Error("Expected: goroutines stack traces dumped; Got: empty dump")
+	}
+}
+
+func TestServerWithCertificateFilesShouldReloadCertificateOnSIGHUP(t *testing.T) {
+	dir := newTempDir(t)
+	defer os.RemoveAll(dir)
+	configs := getTestConfigs()
+	configs.CertFile, configs.KeyFile = newTestCert(t, 1, nil).writeFiles(t, dir)
+	server := New(configs, mux.NewRouter())
+
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+
+	if serial := servedCertificateSerial(t, configs.Port); serial != 1 {
+		t.Fatalf("Expected: certificate 1; Got: %d", serial)
+	}
+
+	newTestCert(t, 2, nil).writeFiles(t, dir)
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+
+	for attempt := 1; ; attempt++ {
+		serial := servedCertificateSerial(t, configs.Port)
+		if serial == 2 {
+			break
+		}
+		if attempt == maxRetries {
+			t.Fatalf("Expected: certificate 2; Got: %d", serial)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerShouldDumpStatsOnSIGUSR1WithoutShuttingDown(t *testing.T) {
+	configs := getSignalTestConfigs()
+	server := New(configs, mux.NewRouter())
+	logs := make(logLines, 10)
+	server.GetHTTPServer().ErrorLog = log.New(logs, "", 0)
+
+	go server.Start()
+	defer server.Stop()
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+
+	select {
+	case line := <-logs:
+		if !strings.HasPrefix(line, "server: stats: in-flight=0 queued=0 total=1 uptime=") || !strings.Contains(line, "goroutines=") {
+			t.Errorf("Expected: stats dumped; Got: %s", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected: stats dumped; Got: nothing logged")
+	}
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+}
+
+// logLines sends each written log line to the channel.
+type logLines chan string
+
+func (l logLines) Write(b []byte) (int, error) {
+	l <- string(b)
+	return len(b), nil
+}
+
+// sendSignalDuringSlowRequest starts a server, fires a slow request, sends the signal while the request is in-flight
+// and waits for the server to stop.
+func sendSignalDuringSlowRequest(t *testing.T, configs *Configs, sig syscall.Signal) (*http.Response, error) {
+	requestStarted := make(chan struct{})
+	router := mux.NewRouter()
+	router.Path(slowEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requestStarted)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(200)
+	})
+	server := New(configs, router)
+
+	startError := make(chan error)
+	go func() {
+		startError <- server.Start()
+	}()
+
+	testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	results := make(chan result)
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, slowEndpoint))
+		results <- result{resp, err}
+	}()
+
+	<-requestStarted
+	syscall.Kill(os.Getpid(), sig)
+
+	if err := <-startError; err != nil {
+		t.Errorf("Expected: success; Got: %s", err.Error())
+	}
+	res := <-results
+	return res.resp, res.err
+}
+
+func getSignalTestConfigs() *Configs {
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+	configs.SignalActions = map[os.Signal]ShutdownBehavior{
+		syscall.SIGTERM: Graceful,
+		syscall.SIGQUIT: DumpAndExit,
+		syscall.SIGUSR2: Immediate,
+	}
+	return configs
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServerWithSlowRequestThresholdShouldLogOnlySlowRequests(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/slow/{id}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	})
+	router.Path("/fast").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	configs := getTestConfigs()
+	configs.SlowRequestThreshold = 50 * time.Millisecond
+	server := New(configs, router)
+	logs := &bytes.Buffer{}
+	server.GetHTTPServer().ErrorLog = log.New(logs, "", 0)
+
+	go server.Start()
+	waitForListener(t, configs.Port)
+	testEndpoint(t, configs.Port, "/fast", 200)
+	testEndpoint(t, configs.Port, "/slow/1", 200)
+	server.Stop()
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected: 1 slow request logged; Got: %q", lines)
+	}
+	if !strings.HasPrefix(lines[0], "server: WARN slow request GET /slow/{id} took ") {
+		t.Errorf("Expected: slow request warning; Got: %s", lines[0])
+	}
+}
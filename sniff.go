@@ -0,0 +1,77 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+)
+
+const (
+	// DefaultContentType holds the content type of the responses without one when content sniffing is disabled.
+	DefaultContentType = "application/octet-stream"
+)
+
+// disableContentSniffing sets the X-Content-Type-Options: nosniff header on all responses and the DefaultContentType on
+// the responses whose handlers didn't set a content type, so net/http doesn't sniff one.
+func disableContentSniffing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		next.ServeHTTP(&noSniffWriter{ResponseWriter: w}, r)
+	})
+}
+
+// noSniffWriter sets the DefaultContentType before the headers are written if no content type was set.
+type noSniffWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+// WriteHeader sets the default content type if needed and delegates the call to the wrapped writer.
+func (w *noSniffWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		// A nil Content-Type header value means the handler explicitly opted out of any content type.
+		if _, ok := w.Header()["Content-Type"]; !ok {
+			w.Header().Set("Content-Type", DefaultContentType)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes the headers if needed and delegates the call to the wrapped writer.
+func (w *noSniffWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the wrapped writer if it supports flushing.
+func (w *noSniffWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer, allowing http.ResponseController to reach it.
+func (w *noSniffWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	unknownContentEndpoint = "/unknown"
+)
+
+func TestDisableContentSniffingShouldSetNoSniffAndDefaultContentType(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path(unknownContentEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>not really html</body></html>"))
+	})
+	configs := getTestConfigs()
+	configs.DisableContentSniffing = true
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		resp := doRequest(t, "GET", configs.Port, unknownContentEndpoint, nil)
+
+		expectHeader(t, resp, "X-Content-Type-Options", "nosniff")
+		expectHeader(t, resp, "Content-Type", DefaultContentType)
+	})
+}
+
+func TestDisableContentSniffingShouldKeepContentTypesSetByHandlers(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path(unknownContentEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	})
+	configs := getTestConfigs()
+	configs.DisableContentSniffing = true
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		resp := doRequest(t, "GET", configs.Port, unknownContentEndpoint, nil)
+
+		expectHeader(t, resp, "X-Content-Type-Options", "nosniff")
+		expectHeader(t, resp, "Content-Type", "application/json")
+	})
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"bytes"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// YAMLContentType holds the default content type of the OpenAPI specs ending in .yaml or .yml.
+	YAMLContentType = "application/yaml"
+
+	// JSONContentType holds the default content type of the other OpenAPI specs.
+	JSONContentType = "application/json"
+)
+
+// RegisterStaticDir serves the files in the fsPath directory under urlPrefix. The requested paths are cleaned and
+// resolved inside fsPath, so requests can't traverse outside of it. Directories without an index.html are listed
+// unless DisableDirectoryListing is set.
+func (s *ServerImpl) RegisterStaticDir(urlPrefix, fsPath string) {
+	s.registerStatic(urlPrefix, http.Dir(fsPath))
+}
+
+// RegisterStaticFS serves the files in fsys under urlPrefix, such as the assets embedded with an embed.FS. Directories
+// without an index.html are listed unless DisableDirectoryListing is set.
+func (s *ServerImpl) RegisterStaticFS(urlPrefix string, fsys fs.FS) {
+	s.registerStatic(urlPrefix, http.FS(fsys))
+}
+
+// registerStatic mounts a file server for files on the route named urlPrefix, matching all paths below urlPrefix.
+func (s *ServerImpl) registerStatic(urlPrefix string, files http.FileSystem) {
+	if s.Configs.DisableDirectoryListing {
+		files = noListingFileSystem{files}
+	}
+	prefix := strings.TrimSuffix(urlPrefix, "/")
+	s.Router.PathPrefix(prefix+"/").Name(urlPrefix).Methods("GET", "HEAD").Handler(http.StripPrefix(prefix, http.FileServer(files)))
+}
+
+// RegisterOpenAPISpec serves spec on path with contentType, such as an OpenAPI or Swagger spec. An empty contentType
+// defaults to YAMLContentType for paths ending in .yaml or .yml, or to JSONContentType otherwise.
+func (s *ServerImpl) RegisterOpenAPISpec(path string, spec []byte, contentType string) {
+	if contentType == "" {
+		contentType = JSONContentType
+		if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+			contentType = YAMLContentType
+		}
+	}
+	s.Router.Path(path).Name(path).Methods("GET", "HEAD").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		http.ServeContent(w, r, path, time.Time{}, bytes.NewReader(spec))
+	})
+}
+
+// noListingFileSystem hides the directories without an index.html, so the file server replies 404 instead of listing them.
+type noListingFileSystem struct {
+	http.FileSystem
+}
+
+// Open opens name, failing with os.ErrNotExist for directories without an index.html.
+func (files noListingFileSystem) Open(name string) (http.File, error) {
+	f, err := files.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		index, err := files.FileSystem.Open(path.Join(name, "index.html"))
+		if err != nil {
+			f.Close()
+			return nil, os.ErrNotExist
+		}
+		index.Close()
+	}
+	return f, nil
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRegisterStaticDirShouldServeTheDirectoryFiles(t *testing.T) {
+	dir := newStaticTestDir(t)
+	defer os.RemoveAll(dir)
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterStaticDir("/static/", filepath.Join(dir, "public"))
+		},
+		func(s Server) {
+			status, body := getBody(t, configs.Port, "/static/hello.txt")
+			if status != 200 || body != "hello" {
+				t.Errorf("Expected: 200 hello; Got: %d %s", status, body)
+			}
+			testEndpoint(t, configs.Port, "/static/sub/", 200)
+			testEndpoint(t, configs.Port, "/static/missing.txt", 404)
+			testEndpoint(t, configs.Port, "/static/%2e%2e/secret.txt", 404)
+		})
+}
+
+func TestRegisterStaticDirWithDirectoryListingDisabledShouldNotListDirectories(t *testing.T) {
+	dir := newStaticTestDir(t)
+	defer os.RemoveAll(dir)
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.DisableDirectoryListing = true
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterStaticDir("/static", filepath.Join(dir, "public"))
+		},
+		func(s Server) {
+			testEndpoint(t, configs.Port, "/static/sub/", 404)
+			testEndpoint(t, configs.Port, "/static/hello.txt", 200)
+		})
+}
+
+func TestRegisterStaticFSShouldServeTheFilesWithTheirContentType(t *testing.T) {
+	fsys := fstest.MapFS{
+		"css/style.css": &fstest.MapFile{Data: []byte("body {}")},
+	}
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterStaticFS("/assets", fsys)
+		},
+		func(s Server) {
+			resp := doRequest(t, "GET", configs.Port, "/assets/css/style.css", nil)
+			if resp.StatusCode != 200 {
+				t.Fatalf("Expected: 200; Got: %d", resp.StatusCode)
+			}
+			expectHeader(t, resp, "Content-Type", "text/css; charset=utf-8")
+			status, body := getBody(t, configs.Port, "/assets/css/style.css")
+			if status != 200 || body != "body {}" {
+				t.Errorf("Expected: 200 body {}; Got: %d %s", status, body)
+			}
+		})
+}
+
+// newStaticTestDir returns a temporary directory with a public directory holding hello.txt and an empty sub directory,
+// next to a secret.txt file that must not be served.
+func TestRegisterOpenAPISpecShouldServeTheSpecWithItsContentType(t *testing.T) {
+	spec := "openapi: 3.0.0\n"
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, mux.NewRouter(), true,
+		func(s Server) {
+			s.RegisterOpenAPISpec("/openapi.yaml", []byte(spec), "")
+			s.RegisterOpenAPISpec("/openapi.json", []byte("{}"), "")
+			s.RegisterOpenAPISpec("/swagger", []byte("{}"), "application/vnd.oai.openapi+json")
+		},
+		func(s Server) {
+			status, body := getBody(t, configs.Port, "/openapi.yaml")
+			if status != 200 || body != spec {
+				t.Errorf("Expected: 200 %q; Got: %d %q", spec, status, body)
+			}
+			expectHeader(t, doRequest(t, "GET", configs.Port, "/openapi.yaml", nil), "Content-Type", YAMLContentType)
+			expectHeader(t, doRequest(t, "GET", configs.Port, "/openapi.json", nil), "Content-Type", JSONContentType)
+			expectHeader(t, doRequest(t, "GET", configs.Port, "/swagger", nil), "Content-Type", "application/vnd.oai.openapi+json")
+		})
+}
+
+func newStaticTestDir(t *testing.T) string {
+	dir := newTempDir(t)
+	if err := os.MkdirAll(filepath.Join(dir, "public", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "public", "hello.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
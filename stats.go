@@ -0,0 +1,76 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"os"
+	"runtime"
+	"time"
+)
+
+// Stats holds the server request counters.
+// TotalRequests holds the number of requests received since the server was created, including the in-flight ones.
+// InFlight holds the number of requests currently being served.
+// Queued holds the number of requests waiting for a worker of the WorkerPoolSize.
+// StartedAt holds the time the server last started. It is zero if the server never started.
+// Uptime holds the time since StartedAt.
+type Stats struct {
+	TotalRequests uint64
+	InFlight      int
+	Queued        int
+	StartedAt     time.Time
+	Uptime        time.Duration
+}
+
+// Stats returns the current request counters of the server.
+func (s *ServerImpl) Stats() Stats {
+	stats := Stats{
+		TotalRequests: s.totalRequests.Load(),
+		InFlight:      s.InFlight(),
+	}
+	if s.concurrencyLimiter != nil {
+		stats.Queued = s.concurrencyLimiter.queued()
+	}
+	if startedAt := s.startedAt.Load(); startedAt != nil {
+		stats.StartedAt = *startedAt
+		stats.Uptime = time.Since(*startedAt)
+	}
+	return stats
+}
+
+// dumpStatsOnSignal logs the server stats each time a signal is received, until done is closed.
+func (s *ServerImpl) dumpStatsOnSignal(signals chan os.Signal, done chan struct{}) {
+	for {
+		select {
+		case <-signals:
+			s.dumpStats()
+		case <-done:
+			return
+		}
+	}
+}
+
+// dumpStats logs the in-flight, queued and total requests, the uptime and the number of goroutines.
+func (s *ServerImpl) dumpStats() {
+	stats := s.Stats()
+	s.logf("server: stats: in-flight=%d queued=%d total=%d uptime=%s goroutines=%d",
+		stats.InFlight, stats.Queued, stats.TotalRequests, stats.Uptime.Round(time.Millisecond), runtime.NumGoroutine())
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestStatsShouldCountTheServedRequests(t *testing.T) {
+	configs := getTestConfigs()
+	server := New(configs, mux.NewRouter())
+	if stats := server.Stats(); stats.TotalRequests != 0 || !stats.StartedAt.IsZero() || stats.Uptime != 0 {
+		t.Errorf("Expected: empty stats before start; Got: %+v", stats)
+	}
+
+	before := time.Now()
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+	for i := 0; i < 3; i++ {
+		testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+	}
+
+	stats := server.Stats()
+	if stats.TotalRequests != 3 {
+		t.Errorf("Expected: 3 total requests; Got: %d", stats.TotalRequests)
+	}
+	if stats.InFlight != 0 {
+		t.Errorf("Expected: 0 in-flight requests; Got: %d", stats.InFlight)
+	}
+	if stats.StartedAt.Before(before) || stats.Uptime <= 0 {
+		t.Errorf("Expected: started after %s with positive uptime; Got: %+v", before, stats)
+	}
+}
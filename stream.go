@@ -0,0 +1,118 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// SSEContentType holds the content type of the server-sent events streams.
+	SSEContentType = "text/event-stream"
+)
+
+// RegisterStreamingRoute registers handler for the long-lived streams, such as WebSocket or server-sent events streams,
+// served on path. The route is not subject to the HandlerTimeout and the connection write deadline set from WriteTimeout
+// is cleared before handler is called. As the write deadline is connection-wide, handlers needing one should set their own
+// per write with http.ResponseController.
+func (s *ServerImpl) RegisterStreamingRoute(path string, handler func(w http.ResponseWriter, r *http.Request)) {
+	s.timeoutExemptPaths[path] = true
+	s.Router.Path(path).Name(path).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Writers not supporting deadlines can't have one set either.
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		handler(w, r)
+	})
+}
+
+// ResetDeadlines sets the read and write deadlines of the connection serving w, such as to extend them for a long
+// polling request beyond the ReadTimeout and WriteTimeout. A zero time clears the deadline. It relies on
+// http.ResponseController, requiring Go 1.20 or later, and fails with http.ErrNotSupported if w doesn't support deadlines.
+func ResetDeadlines(w http.ResponseWriter, readDeadline, writeDeadline time.Time) error {
+	controller := http.NewResponseController(w)
+	if err := controller.SetReadDeadline(readDeadline); err != nil {
+		return err
+	}
+	return controller.SetWriteDeadline(writeDeadline)
+}
+
+// SSEWriter writes server-sent events to a response, flushing each event as it's sent.
+type SSEWriter struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+}
+
+// NewSSEWriter sets the server-sent events headers on w, clears the connection write deadline set from WriteTimeout and
+// sends the headers, returning a writer of events to w. Writers not supporting deadlines keep theirs.
+func NewSSEWriter(w http.ResponseWriter) *SSEWriter {
+	controller := http.NewResponseController(w)
+	controller.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", SSEContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	// Ask reverse proxies such as nginx not to buffer the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	controller.Flush()
+	return &SSEWriter{w: w, controller: controller}
+}
+
+// Send sends an event named event holding data, writing a data line per line of data, and flushes it. An empty event
+// omits the event name, so clients dispatch it as a message event.
+func (s *SSEWriter) Send(event, data string) error {
+	var b strings.Builder
+	if event != "" {
+		fmt.Fprintf(&b, "event: %s\n", event)
+	}
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	if _, err := s.w.Write([]byte(b.String())); err != nil {
+		return err
+	}
+	return s.controller.Flush()
+}
+
+// AcquireStream reserves one of the MaxStreamingConnections slots for the long-lived stream served to r, such as a
+// server-sent events or WebSocket stream. ok is false when all slots are taken, in which case handlers should reply 503.
+// Otherwise release must be called once the stream ends. Without MaxStreamingConnections all streams are accepted.
+func AcquireStream(r *http.Request) (release func(), ok bool) {
+	state, found := r.Context().Value(requestStateKey).(*requestState)
+	if !found || state.server.streams == nil {
+		return func() {}, true
+	}
+
+	streams := state.server.streams
+	select {
+	case streams <- struct{}{}:
+	default:
+		return nil, false
+	}
+	released := false
+	return func() {
+		if !released {
+			released = true
+			<-streams
+		}
+	}, true
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	streamEndpoint = "/stream"
+)
+
+func TestAcquireStreamShouldRefuseStreamsAboveMaxStreamingConnections(t *testing.T) {
+	streaming := make(chan struct{})
+	endStreams := make(chan struct{})
+	router := mux.NewRouter()
+	router.Path(streamEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		release, ok := AcquireStream(r)
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+		streaming <- struct{}{}
+		<-endStreams
+	})
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+	configs.MaxStreamingConnections = 2
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		for i := 0; i < configs.MaxStreamingConnections; i++ {
+			go http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, streamEndpoint))
+			select {
+			case <-streaming:
+			case <-time.After(time.Second):
+				t.Fatal("Expected: stream accepted; Got: timeout")
+			}
+		}
+
+		testEndpoint(t, configs.Port, streamEndpoint, http.StatusServiceUnavailable)
+
+		close(endStreams)
+	})
+}
+
+func TestAcquireStreamWithoutMaxStreamingConnectionsShouldAcceptAllStreams(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path(streamEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		release, ok := AcquireStream(r)
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		release()
+		w.WriteHeader(200)
+	})
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		testEndpoint(t, configs.Port, streamEndpoint, 200)
+	})
+}
+
+func TestRegisterStreamingRouteShouldWritePastTheTimeouts(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.WriteTimeout = 100 * time.Millisecond
+	configs.HandlerTimeout = 50 * time.Millisecond
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterStreamingRoute("/events", func(w http.ResponseWriter, r *http.Request) {
+				for i := 0; i < 4; i++ {
+					fmt.Fprintf(w, "event %d\n", i)
+					w.(http.Flusher).Flush()
+					time.Sleep(50 * time.Millisecond)
+				}
+			})
+		},
+		func(s Server) {
+			status, body := getBody(t, configs.Port, "/events")
+			if status != 200 {
+				t.Fatalf("Expected: 200; Got: %d", status)
+			}
+			if expected := "event 0\nevent 1\nevent 2\nevent 3\n"; body != expected {
+				t.Errorf("Expected: %q; Got: %q", expected, body)
+			}
+		})
+}
+
+func TestResetDeadlinesShouldLetHandlersWritePastTheWriteTimeout(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path("/poll").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := ResetDeadlines(w, time.Time{}, time.Now().Add(time.Second)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		time.Sleep(150 * time.Millisecond)
+		fmt.Fprint(w, "polled")
+	})
+	configs := getTestConfigs()
+	configs.WriteTimeout = 100 * time.Millisecond
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		status, body := getBody(t, configs.Port, "/poll")
+		if status != 200 || body != "polled" {
+			t.Errorf("Expected: 200 polled; Got: %d %q", status, body)
+		}
+	})
+}
+
+func TestSSEWriterShouldSendEachEventAsItsSent(t *testing.T) {
+	next := make(chan struct{})
+	router := mux.NewRouter()
+	router.Path(streamEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		events := NewSSEWriter(w)
+		if err := events.Send("greeting", "hello"); err != nil {
+			t.Error(err)
+		}
+		<-next
+		if err := events.Send("", "multi\nline"); err != nil {
+			t.Error(err)
+		}
+	})
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		resp, err := http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, streamEndpoint))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		expectHeader(t, resp, "Content-Type", SSEContentType)
+		reader := bufio.NewReader(resp.Body)
+
+		if event := readEvent(t, reader); event != "event: greeting\ndata: hello\n" {
+			t.Errorf("Expected: greeting event before the next one is sent; Got: %q", event)
+		}
+		close(next)
+		if event := readEvent(t, reader); event != "data: multi\ndata: line\n" {
+			t.Errorf("Expected: multiline message event; Got: %q", event)
+		}
+	})
+}
+
+// readEvent reads the lines of the next server-sent event, up to the blank line ending it.
+func readEvent(t *testing.T, reader *bufio.Reader) string {
+	var event strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line == "\n" {
+			return event.String()
+		}
+		event.WriteString(line)
+	}
+}
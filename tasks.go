@@ -0,0 +1,120 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// RegisterShutdownTask registers a function that runs concurrently with the other tasks and the shutdown of the HTTP
+// server. Unlike the RegisterOnShutdown functions, tasks receive a context expiring after the CleanupTimeout and the
+// shutdown waits for them to return, or for the context to expire, before completing.
+func (s *ServerImpl) RegisterShutdownTask(f func(ctx context.Context) error) {
+	s.shutdownTasks = append(s.shutdownTasks, f)
+}
+
+// startShutdownTasks starts all shutdown tasks, returning a function that waits for them to return or for ctx to expire
+// and returns their joined errors.
+func (s *ServerImpl) startShutdownTasks(ctx context.Context) (wait func() error) {
+	tasks := s.shutdownTasks
+	errs := make(chan error, len(tasks))
+	for _, task := range tasks {
+		go func(task func(ctx context.Context) error) {
+			errs <- task(ctx)
+		}(task)
+	}
+	return func() error {
+		var taskErrs []error
+		for range tasks {
+			select {
+			case err := <-errs:
+				if err != nil {
+					taskErrs = append(taskErrs, err)
+				}
+			case <-ctx.Done():
+				return errors.Join(append(taskErrs, ctx.Err())...)
+			}
+		}
+		return errors.Join(taskErrs...)
+	}
+}
+
+// RegisterCleanup registers a function that runs once the HTTP server stopped accepting connections and the in-flight
+// requests finished, or were closed. Cleanups run in registration order with the CleanupTimeout budget left after the
+// drain, all of them running even if some fail.
+func (s *ServerImpl) RegisterCleanup(f func(ctx context.Context) error) {
+	s.cleanups = append(s.cleanups, f)
+}
+
+// runCleanups runs all cleanups in registration order, returning their joined errors.
+func (s *ServerImpl) runCleanups(ctx context.Context) error {
+	var errs []error
+	for i, cleanup := range s.cleanups {
+		if err := cleanup(ctx); err != nil {
+			s.logf("server: cleanup %d failed: %v", i, err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RegisterShutdownFlusher registers a function flushing the state collected while serving, such as pushing the final
+// metrics to a Prometheus Pushgateway, once the shutdown phases and the post-drain hook ran, so every request is
+// accounted for. Flushers run in registration order with the ShutdownTimeout budget of the post-drain hook. They are
+// best-effort: their errors are logged without failing the shutdown.
+func (s *ServerImpl) RegisterShutdownFlusher(f func(ctx context.Context) error) {
+	s.flushers = append(s.flushers, f)
+}
+
+// runFlushers runs all flushers in registration order, logging their errors.
+func (s *ServerImpl) runFlushers(ctx context.Context) {
+	for i, flush := range s.flushers {
+		if err := flush(ctx); err != nil {
+			s.logf("server: shutdown flusher %d failed: %v", i, err)
+		}
+	}
+}
+
+// TrackGoroutine tracks a background goroutine, such as one spawned by a handler, that should finish before the server
+// stops. The shutdown waits for all tracked goroutines after the HTTP server drains, up to the CleanupTimeout.
+// done must be called once the goroutine finishes; later calls are ignored.
+func (s *ServerImpl) TrackGoroutine() (done func()) {
+	s.goroutines.add()
+	var once sync.Once
+	return func() {
+		once.Do(s.goroutines.done)
+	}
+}
+
+// waitGoroutines waits for all tracked goroutines to finish, returning the ctx error if it expires first.
+func (s *ServerImpl) waitGoroutines(ctx context.Context) error {
+	if s.goroutines.len() == 0 {
+		return nil
+	}
+	select {
+	case <-s.goroutines.wait():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestShutdownTaskBlockingShouldNotDelayStopPastTheShutdownTimeout(t *testing.T) {
+	blocked := make(chan struct{})
+	defer close(blocked)
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = 100 * time.Millisecond
+
+	runTestServer(t, configs, router, false,
+		func(s Server) {
+			s.RegisterShutdownTask(func(ctx context.Context) error {
+				<-blocked
+				return nil
+			})
+		},
+		func(s Server) {
+			start := time.Now()
+			if err := s.Stop(); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("Expected: %v; Got: %v", context.DeadlineExceeded, err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Expected: Stop to return at the shutdown timeout; Got: %s", elapsed)
+			}
+		})
+}
+
+func TestShutdownTasksShouldRunConcurrentlyAndJoinTheirErrors(t *testing.T) {
+	testError := errors.New("Simulate task error")
+	started := make(chan struct{}, 2)
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+
+	runTestServer(t, configs, router, false,
+		func(s Server) {
+			task := func(err error) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					started <- struct{}{}
+					// Each task waits for the other to start, which only happens if they run concurrently.
+					for len(started) < 2 {
+						select {
+						case <-ctx.Done():
+							return ctx.Err()
+						case <-time.After(time.Millisecond):
+						}
+					}
+					return err
+				}
+			}
+			s.RegisterShutdownTask(task(nil))
+			s.RegisterShutdownTask(task(testError))
+		},
+		func(s Server) {
+			if err := s.Stop(); !errors.Is(err, testError) {
+				t.Errorf("Expected: %v; Got: %v", testError, err)
+			}
+		})
+}
+
+func TestCleanupsShouldRunInOrderAfterTheServerStoppedAcceptingAndDrained(t *testing.T) {
+	var requestDoneAt, firstCleanupAt, secondCleanupAt time.Time
+	// OnShutdown functions run on a goroutine apart, so the time is sent over a channel.
+	shutdownAt := make(chan time.Time, 1)
+	requestStarted := make(chan struct{})
+	router := mux.NewRouter()
+	router.Path(slowEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requestStarted)
+		time.Sleep(50 * time.Millisecond)
+		requestDoneAt = time.Now()
+	})
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+	server := New(configs, router)
+	server.RegisterOnShutdown(func() {
+		shutdownAt <- time.Now()
+	})
+	server.RegisterCleanup(func(ctx context.Context) error {
+		firstCleanupAt = time.Now()
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("Expected: cleanup context with the remaining shutdown budget; Got: no deadline")
+		}
+		return nil
+	})
+	server.RegisterCleanup(func(ctx context.Context) error {
+		secondCleanupAt = time.Now()
+		return nil
+	})
+
+	go server.Start()
+	waitForListener(t, configs.Port)
+	go http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, slowEndpoint))
+	<-requestStarted
+	if err := server.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case at := <-shutdownAt:
+		if requestDoneAt.Before(at) {
+			t.Errorf("Expected: shutdown to begin while the request is in-flight; Got: shutdown at %s, request done at %s", at, requestDoneAt)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected: shutdown to begin while the request is in-flight; Got: OnShutdown not called")
+	}
+	if firstCleanupAt.Before(requestDoneAt) {
+		t.Errorf("Expected: cleanup after the drain; Got: cleanup at %s, request done at %s", firstCleanupAt, requestDoneAt)
+	}
+	if secondCleanupAt.Before(firstCleanupAt) {
+		t.Errorf("Expected: cleanups in registration order; Got: %s before %s", secondCleanupAt, firstCleanupAt)
+	}
+}
+
+func TestCleanupsShouldAllRunAndJoinTheirErrors(t *testing.T) {
+	testError := errors.New("Simulate cleanup error")
+	ran := 0
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, false,
+		func(s Server) {
+			s.RegisterCleanup(func(ctx context.Context) error {
+				ran++
+				return testError
+			})
+			s.RegisterCleanup(func(ctx context.Context) error {
+				ran++
+				return nil
+			})
+		},
+		func(s Server) {
+			if err := s.Stop(); !errors.Is(err, testError) {
+				t.Errorf("Expected: %v; Got: %v", testError, err)
+			}
+			if ran != 2 {
+				t.Errorf("Expected: 2 cleanups run; Got: %d", ran)
+			}
+		})
+}
+
+func TestShutdownFlushersShouldPushTheFinalStatsAndNotFailTheShutdown(t *testing.T) {
+	pushed := make(chan string, 1)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		pushed <- r.Method + " " + r.URL.Path + " " + string(body)
+	}))
+	defer gateway.Close()
+	var cleanedUp bool
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+
+	runTestServer(t, configs, router, false,
+		func(s Server) {
+			s.RegisterCleanup(func(ctx context.Context) error {
+				cleanedUp = true
+				return nil
+			})
+			s.RegisterShutdownFlusher(func(ctx context.Context) error {
+				return errors.New("Simulate flusher error")
+			})
+			s.RegisterShutdownFlusher(func(ctx context.Context) error {
+				if !cleanedUp {
+					return errors.New("flushed before the cleanups")
+				}
+				body := fmt.Sprintf("server_requests_total %d\n", s.Stats().TotalRequests)
+				req, err := http.NewRequestWithContext(ctx, "PUT", gateway.URL+"/metrics/job/server", strings.NewReader(body))
+				if err != nil {
+					return err
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					return err
+				}
+				return resp.Body.Close()
+			})
+		},
+		func(s Server) {
+			testEndpoint(t, configs.Port, DefaultPingEndpoint, 200)
+			total := s.Stats().TotalRequests
+
+			if err := s.Stop(); err != nil {
+				t.Errorf("Expected: success despite the failing flusher; Got: %v", err)
+			}
+			select {
+			case got := <-pushed:
+				if expected := fmt.Sprintf("PUT /metrics/job/server server_requests_total %d\n", total); got != expected {
+					t.Errorf("Expected: %q pushed; Got: %q", expected, got)
+				}
+			default:
+				t.Error("Expected: the final stats pushed on shutdown; Got: nothing pushed")
+			}
+		})
+}
+
+func TestStopShouldWaitForTrackedGoroutinesToFinish(t *testing.T) {
+	finished := false
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+
+	runTestServer(t, configs, router, false, nil, func(s Server) {
+		done := s.TrackGoroutine()
+		go func() {
+			defer done()
+			time.Sleep(50 * time.Millisecond)
+			finished = true
+		}()
+		if err := s.Stop(); err != nil {
+			t.Errorf("Expected: success; Got: %v", err)
+		}
+		if !finished {
+			t.Error("Expected: Stop to wait for the tracked goroutine; Got: returned before it finished")
+		}
+	})
+}
+
+func TestStopShouldNotWaitForTrackedGoroutinesPastTheShutdownTimeout(t *testing.T) {
+	blocked := make(chan struct{})
+	defer close(blocked)
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = 100 * time.Millisecond
+
+	runTestServer(t, configs, router, false, nil, func(s Server) {
+		done := s.TrackGoroutine()
+		go func() {
+			defer done()
+			<-blocked
+		}()
+		start := time.Now()
+		if err := s.Stop(); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected: %v; Got: %v", context.DeadlineExceeded, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected: Stop to return at the shutdown timeout; Got: %s", elapsed)
+		}
+	})
+}
+
+func TestDrainTimeoutShouldBoundTheDrainIndependentlyOfTheShutdownTimeout(t *testing.T) {
+	requestStarted := make(chan struct{})
+	router := mux.NewRouter()
+	router.Path(slowEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requestStarted)
+		time.Sleep(time.Second)
+	})
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+	configs.DrainTimeout = 50 * time.Millisecond
+	configs.UninterruptibleTimeout = 50 * time.Millisecond
+	server := New(configs, router)
+	cleanupErr := make(chan error, 1)
+	server.RegisterCleanup(func(ctx context.Context) error {
+		cleanupErr <- ctx.Err()
+		return nil
+	})
+
+	go server.Start()
+	waitForListener(t, configs.Port)
+	go http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, slowEndpoint))
+	<-requestStarted
+	start := time.Now()
+	if err := server.Stop(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected: %v; Got: %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected: Stop to return at the drain timeout; Got: %s", elapsed)
+	}
+	if err := <-cleanupErr; err != nil {
+		t.Errorf("Expected: cleanup budget left after the drain; Got: %v", err)
+	}
+}
+
+func TestCleanupTimeoutShouldBoundTheShutdownTasksIndependentlyOfTheDrain(t *testing.T) {
+	blocked := make(chan struct{})
+	defer close(blocked)
+	requestStarted := make(chan struct{})
+	router := mux.NewRouter()
+	router.Path(slowEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requestStarted)
+		time.Sleep(100 * time.Millisecond)
+	})
+	configs := getTestConfigs()
+	configs.ShutdownTimeout = DefaultShutdownTimeout
+	configs.CleanupTimeout = 50 * time.Millisecond
+	server := New(configs, router)
+	server.RegisterShutdownTask(func(ctx context.Context) error {
+		<-blocked
+		return nil
+	})
+
+	go server.Start()
+	waitForListener(t, configs.Port)
+	requestErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("%s:%d%s", testServerEndpoint, configs.Port, slowEndpoint))
+		if err == nil {
+			resp.Body.Close()
+		}
+		requestErr <- err
+	}()
+	<-requestStarted
+	start := time.Now()
+	if err := server.Stop(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected: %v; Got: %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected: Stop to return at the cleanup timeout; Got: %s", elapsed)
+	}
+	if err := <-requestErr; err != nil {
+		t.Errorf("Expected: in-flight request drained within the shutdown timeout; Got: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestHandlerTimeoutShouldReply503ToSlowHandlers(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path(slowEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(200)
+	})
+	configs := getTestConfigs()
+	configs.HandlerTimeout = 20 * time.Millisecond
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		status, body := getBody(t, configs.Port, slowEndpoint)
+		if status != http.StatusServiceUnavailable {
+			t.Errorf("Expected: 503; Got: %d", status)
+		}
+		if body != DefaultHandlerTimeoutMessage+"\n" {
+			t.Errorf("Expected: %s; Got: %s", DefaultHandlerTimeoutMessage, body)
+		}
+	})
+}
+
+func TestHandlerTimeoutShouldNotApplyToShutdownEndpoint(t *testing.T) {
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.HandlerTimeout = 20 * time.Millisecond
+	server := New(configs, router).(*ServerImpl)
+
+	if !server.timeoutExemptPaths[DefaultShutdownEndpoint] {
+		t.Error("Expected: shutdown endpoint exempt from the handler timeout; Got: not exempt")
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+)
+
+var (
+	// DefaultTLSNextProtos holds the protocols negotiated through ALPN when TLSNextProtos is not set.
+	DefaultTLSNextProtos = []string{"h2", "http/1.1"}
+)
+
+// newTLSConfig returns the TLS config serving the certificates returned by getCertificate and, when a ClientCAFile is
+// configured, verifying client certificates.
+func (s *ServerImpl) newTLSConfig(getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) (*tls.Config, error) {
+	nextProtos := s.Configs.TLSNextProtos
+	if len(nextProtos) == 0 {
+		nextProtos = DefaultTLSNextProtos
+	}
+	if !containsString(nextProtos, "h2") {
+		// A non-nil TLSNextProto map keeps the HTTP server from configuring HTTP/2.
+		s.HTTPServer.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+	config := &tls.Config{
+		GetCertificate: getCertificate,
+		NextProtos:     append([]string(nil), nextProtos...),
+	}
+	if s.Configs.ClientCAFile == "" {
+		if s.Configs.RequireClientCert {
+			return nil, errors.New("server: RequireClientCert requires a ClientCAFile")
+		}
+		return config, nil
+	}
+
+	pem, err := ioutil.ReadFile(s.Configs.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	config.ClientCAs = x509.NewCertPool()
+	if !config.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, errors.New("server: no certificates found in ClientCAFile " + s.Configs.ClientCAFile)
+	}
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	if s.Configs.RequireClientCert {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// certificateReloader serves a TLS certificate loaded from files, allowing the files to be reloaded without restarting the server.
+type certificateReloader struct {
+	mu       sync.RWMutex
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+}
+
+// newCertificateReloader returns a reloader serving the certificate loaded from certFile and keyFile.
+func newCertificateReloader(certFile, keyFile string) (*certificateReloader, error) {
+	r := &certificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload loads the certificate files again. The previous certificate keeps being served if the files can't be loaded.
+func (r *certificateReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	return nil
+}
+
+// getCertificate returns the current certificate. It's meant to be used as tls.Config.GetCertificate.
+func (r *certificateReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// reloadCertificateOnSignal reloads the certificate files whenever a signal is received on signals, until done is closed.
+func (s *ServerImpl) reloadCertificateOnSignal(signals chan os.Signal, done chan struct{}) {
+	for {
+		select {
+		case <-signals:
+			if err := s.certificates.reload(); err != nil {
+				s.logf("server: failed to reload TLS certificate, keeping the previous one: %v", err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
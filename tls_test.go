@@ -0,0 +1,283 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServerWithCertificateFilesShouldServeHTTPS(t *testing.T) {
+	dir := newTempDir(t)
+	defer os.RemoveAll(dir)
+	configs := getTestConfigs()
+	configs.CertFile, configs.KeyFile = newTestCert(t, 1, nil).writeFiles(t, dir)
+	server := New(configs, mux.NewRouter())
+
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get(fmt.Sprintf("https://localhost:%d%s", configs.Port, DefaultPingEndpoint))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected: 200; Got: %d", resp.StatusCode)
+	}
+	if resp.TLS == nil || resp.TLS.PeerCertificates[0].SerialNumber.Int64() != 1 {
+		t.Error("Expected: response served over TLS with the configured certificate; Got: other certificate")
+	}
+}
+
+func TestServerWithCertificateFilesShouldNegotiateTheTLSNextProtos(t *testing.T) {
+	tests := []struct {
+		nextProtos []string
+		expected   string
+	}{
+		{nil, "h2"},
+		{[]string{"http/1.1", "h2"}, "http/1.1"},
+		{[]string{"http/1.1"}, "http/1.1"},
+	}
+	for _, test := range tests {
+		dir := newTempDir(t)
+		defer os.RemoveAll(dir)
+		configs := getTestConfigs()
+		configs.CertFile, configs.KeyFile = newTestCert(t, 1, nil).writeFiles(t, dir)
+		configs.TLSNextProtos = test.nextProtos
+		server := New(configs, mux.NewRouter())
+
+		go server.Start()
+		waitForListener(t, configs.Port)
+
+		conn, err := tls.Dial("tcp", fmt.Sprintf("localhost:%d", configs.Port), &tls.Config{
+			InsecureSkipVerify: true,
+			NextProtos:         []string{"h2", "http/1.1"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if protocol := conn.ConnectionState().NegotiatedProtocol; protocol != test.expected {
+			t.Errorf("Expected: %s negotiated with %v; Got: %s", test.expected, test.nextProtos, protocol)
+		}
+		conn.Close()
+
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		}}
+		resp, err := client.Get(fmt.Sprintf("https://localhost:%d%s", configs.Port, DefaultPingEndpoint))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if expectedProto := map[string]int{"h2": 2, "http/1.1": 1}[test.expected]; resp.ProtoMajor != expectedProto {
+			t.Errorf("Expected: HTTP/%d with %v; Got: %s", expectedProto, test.nextProtos, resp.Proto)
+		}
+		client.CloseIdleConnections()
+		server.Stop()
+	}
+}
+
+func TestServerWithTLSConfigShouldServeHTTPSWithIt(t *testing.T) {
+	dir := newTempDir(t)
+	defer os.RemoveAll(dir)
+	testCert := newTestCert(t, 2, nil)
+	cert, err := tls.X509KeyPair(testCert.certPEM, testCert.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs := getTestConfigs()
+	configs.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	// The TLS config takes precedence over the certificate files.
+	configs.CertFile, configs.KeyFile = newTestCert(t, 1, nil).writeFiles(t, dir)
+	server := New(configs, mux.NewRouter())
+
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	resp, err := client.Get(fmt.Sprintf("https://localhost:%d%s", configs.Port, DefaultPingEndpoint))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected: 200; Got: %d", resp.StatusCode)
+	}
+	if resp.TLS == nil || resp.TLS.PeerCertificates[0].SerialNumber.Int64() != 2 {
+		t.Error("Expected: response served over TLS with the TLS config certificate; Got: other certificate")
+	}
+}
+
+func TestServerWithMissingCertificateFilesShouldReturnErrorOnStart(t *testing.T) {
+	configs := getTestConfigs()
+	configs.CertFile, configs.KeyFile = "missing.crt", "missing.key"
+	server := New(configs, mux.NewRouter())
+
+	if err := server.Start(); err == nil {
+		t.Error("Expected: error as the certificate files don't exist; Got: success")
+	}
+}
+
+func TestServerWithRequiredClientCertShouldOnlyAcceptClientsWithValidCertificates(t *testing.T) {
+	dir := newTempDir(t)
+	defer os.RemoveAll(dir)
+	ca := newTestCert(t, 1, nil)
+	clientCert := newTestCert(t, 3, ca)
+	configs := getTestConfigs()
+	configs.CertFile, configs.KeyFile = newTestCert(t, 2, ca).writeFiles(t, dir)
+	configs.ClientCAFile = filepath.Join(dir, "ca.crt")
+	configs.RequireClientCert = true
+	if err := ioutil.WriteFile(configs.ClientCAFile, ca.certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	server := New(configs, mux.NewRouter())
+
+	go server.Start()
+	defer server.Stop()
+	waitForListener(t, configs.Port)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	cert, err := tls.X509KeyPair(clientCert.certPEM, clientCert.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticated := &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{cert}}
+	conn, err := tls.Dial("tcp", fmt.Sprintf("localhost:%d", configs.Port), authenticated)
+	if err != nil {
+		t.Fatalf("Expected: handshake with a valid client certificate to succeed; Got: %s", err.Error())
+	}
+	conn.Close()
+
+	unauthenticated := &tls.Config{RootCAs: roots}
+	conn, err = tls.Dial("tcp", fmt.Sprintf("localhost:%d", configs.Port), unauthenticated)
+	if err == nil {
+		// With TLS 1.3 the client learns the handshake failed on its first read.
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	if err == nil {
+		t.Error("Expected: handshake without a client certificate to fail; Got: success")
+	}
+}
+
+// testCert holds a certificate generated for tests.
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert generates a certificate valid for localhost with the given serial number, signed by parent.
+// A nil parent generates a self-signed CA certificate.
+func newTestCert(t *testing.T, serial int64, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// writeFiles writes the certificate and key PEM files into dir, returning their paths.
+func (c *testCert) writeFiles(t *testing.T, dir string) (certFile, keyFile string) {
+	certFile, keyFile = filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	if err := ioutil.WriteFile(certFile, c.certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, c.keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// servedCertificateSerial performs a TLS handshake with the server on port, returning the serial number of the served certificate.
+func servedCertificateSerial(t *testing.T, port int) int64 {
+	conn, err := tls.Dial("tcp", fmt.Sprintf("localhost:%d", port), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+}
+
+func newTempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
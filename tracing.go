@@ -0,0 +1,67 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Tracer starts a server span for each request when tracing is enabled. It is kept minimal so tracing libraries, such
+// as OpenTelemetry, can be plugged in through a thin adapter without the server depending on them.
+type Tracer interface {
+	// StartSpan starts a span named name for r, continuing the trace propagated in its headers, such as traceparent.
+	// The returned context carries the span and is passed down to the handlers.
+	StartSpan(r *http.Request, name string) (context.Context, Span)
+}
+
+// Span represents a request span started by a Tracer.
+type Span interface {
+	// End records the response status code and ends the span.
+	End(statusCode int)
+}
+
+// trace starts a span for each request, named by the method and the path template of the matched route.
+func (s *ServerImpl) trace(next http.Handler) http.Handler {
+	tracer := s.Configs.Tracer
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.StartSpan(r, s.spanName(r))
+		rw := newResponseWriter(w)
+		defer func() {
+			span.End(rw.status)
+		}()
+		next.ServeHTTP(rw, r.WithContext(ctx))
+	})
+}
+
+// spanName returns the method and the path template of the route matching r, or only the method for unmatched requests
+// so unknown paths don't create an unbounded number of span names.
+func (s *ServerImpl) spanName(r *http.Request) string {
+	var match mux.RouteMatch
+	if s.currentRouter().Match(r, &match) && match.Route != nil {
+		if template, err := match.Route.GetPathTemplate(); err == nil {
+			return r.Method + " " + template
+		}
+	}
+	return r.Method
+}
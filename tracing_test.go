@@ -0,0 +1,129 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+type testSpanKey struct{}
+
+// testSpan records a span in memory.
+type testSpan struct {
+	name        string
+	traceParent string
+	status      int
+	tracer      *testTracer
+}
+
+func (s *testSpan) End(statusCode int) {
+	s.status = statusCode
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.ended = append(s.tracer.ended, s)
+}
+
+// testTracer exports the ended spans in memory.
+type testTracer struct {
+	mu    sync.Mutex
+	ended []*testSpan
+}
+
+func (t *testTracer) StartSpan(r *http.Request, name string) (context.Context, Span) {
+	span := &testSpan{name: name, traceParent: r.Header.Get("traceparent"), tracer: t}
+	return context.WithValue(r.Context(), testSpanKey{}, span), span
+}
+
+func (t *testTracer) spans() []*testSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*testSpan(nil), t.ended...)
+}
+
+func TestServerWithTracingShouldRecordASpanPerRequest(t *testing.T) {
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tracer := &testTracer{}
+	router := mux.NewRouter()
+	configs := getTestConfigs()
+	configs.EnableTracing = true
+	configs.Tracer = tracer
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		resp := doRequest(t, "GET", configs.Port, DefaultPingEndpoint, http.Header{"Traceparent": {traceParent}})
+		if resp.StatusCode != 200 {
+			t.Fatalf("Expected: 200; Got: %d", resp.StatusCode)
+		}
+	})
+
+	var span *testSpan
+	for _, ended := range tracer.spans() {
+		if ended.traceParent == traceParent {
+			span = ended
+		}
+	}
+	if span == nil {
+		t.Fatalf("Expected: span recorded for %s; Got: %v", DefaultPingEndpoint, tracer.spans())
+	}
+	if span.name != "GET "+DefaultPingEndpoint {
+		t.Errorf("Expected: GET %s; Got: %s", DefaultPingEndpoint, span.name)
+	}
+	if span.status != 200 {
+		t.Errorf("Expected: 200; Got: %d", span.status)
+	}
+}
+
+func TestServerWithTracingShouldPassTheSpanContextToHandlers(t *testing.T) {
+	spans := make(chan interface{}, 1)
+	router := mux.NewRouter()
+	router.Path("/users/{id}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		spans <- r.Context().Value(testSpanKey{})
+	})
+	configs := getTestConfigs()
+	configs.EnableTracing = true
+	configs.Tracer = &testTracer{}
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		testEndpoint(t, configs.Port, "/users/1", 200)
+	})
+
+	span, ok := (<-spans).(*testSpan)
+	if !ok {
+		t.Fatal("Expected: span in the handler context; Got: none")
+	}
+	if span.name != "GET /users/{id}" {
+		t.Errorf("Expected: GET /users/{id}; Got: %s", span.name)
+	}
+}
+
+func TestServerWithTracingWithoutTracerShouldReturnErrorOnStart(t *testing.T) {
+	configs := getTestConfigs()
+	configs.EnableTracing = true
+	server := New(configs, mux.NewRouter())
+
+	if err := server.Start(); err != ErrTracerRequired {
+		t.Errorf("Expected: %v; Got: %v", ErrTracerRequired, err)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ResponseTransformer transforms the buffered response body of a request before it's sent to the client.
+type ResponseTransformer = func(body []byte, r *http.Request) []byte
+
+// RegisterResponseTransformer registers a function that transforms the response bodies of the route named routeName.
+// Responses are buffered up to ResponseTransformerMaxSize; bigger responses are sent to the client untransformed.
+func (s *ServerImpl) RegisterResponseTransformer(routeName string, transform ResponseTransformer) {
+	if s.responseTransformers == nil {
+		s.responseTransformers = make(map[string]ResponseTransformer)
+		s.Router.Use(s.transformResponse)
+	}
+	s.responseTransformers[routeName] = transform
+}
+
+// transformResponse buffers and transforms the responses of the routes with a registered transformer.
+func (s *ServerImpl) transformResponse(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		transform, ok := s.responseTransformers[route.GetName()]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		maxSize := s.Configs.ResponseTransformerMaxSize
+		if maxSize <= 0 {
+			maxSize = DefaultResponseTransformerMaxSize
+		}
+		tw := &transformWriter{
+			ResponseWriter: w,
+			status:         http.StatusOK,
+			maxSize:        maxSize,
+		}
+		next.ServeHTTP(tw, r)
+		if tw.passthrough {
+			return
+		}
+
+		body := transform(tw.buf.Bytes(), r)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(tw.status)
+		w.Write(body)
+	})
+}
+
+// transformWriter buffers the response up to maxSize. Once the response outgrows maxSize, the buffered content is
+// flushed and the remaining writes pass through to the wrapped writer.
+type transformWriter struct {
+	http.ResponseWriter
+	status      int
+	buf         bytes.Buffer
+	maxSize     int
+	passthrough bool
+}
+
+// WriteHeader records the status code to be sent after the body is transformed.
+func (w *transformWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+// Write buffers b, switching to passthrough mode if the buffered response grows bigger than maxSize.
+func (w *transformWriter) Write(b []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.buf.Len()+len(b) <= w.maxSize {
+		return w.buf.Write(b)
+	}
+
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.status)
+	if _, err := w.ResponseWriter.Write(w.buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	transformedEndpoint = "/transformed"
+)
+
+func TestRegisterResponseTransformerShouldTransformMatchedRouteResponses(t *testing.T) {
+	router := mux.NewRouter()
+	router.Path(transformedEndpoint).Name(transformedEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(201)
+		w.Write([]byte("<p>{{nonce}}</p>"))
+	})
+	configs := getTestConfigs()
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterResponseTransformer(transformedEndpoint, func(body []byte, r *http.Request) []byte {
+				return bytes.Replace(body, []byte("{{nonce}}"), []byte("abc123"), -1)
+			})
+		},
+		func(s Server) {
+			status, body := getBody(t, configs.Port, transformedEndpoint)
+			if status != 201 {
+				t.Errorf("Expected: 201; Got: %d", status)
+			}
+			if body != "<p>abc123</p>" {
+				t.Errorf("Expected: <p>abc123</p>; Got: %s", body)
+			}
+		})
+}
+
+func TestRegisterResponseTransformerShouldNotTransformResponsesBiggerThanMaxSize(t *testing.T) {
+	content := strings.Repeat("a", 100)
+	router := mux.NewRouter()
+	router.Path(transformedEndpoint).Name(transformedEndpoint).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	})
+	configs := getTestConfigs()
+	configs.ResponseTransformerMaxSize = 10
+
+	runTestServer(t, configs, router, true,
+		func(s Server) {
+			s.RegisterResponseTransformer(transformedEndpoint, func(body []byte, r *http.Request) []byte {
+				return []byte("transformed")
+			})
+		},
+		func(s Server) {
+			if _, body := getBody(t, configs.Port, transformedEndpoint); body != content {
+				t.Errorf("Expected: untransformed body; Got: %s", body)
+			}
+		})
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2018 cloud-spin
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestServerWithMaxURLLengthShouldReply414ToLongerURIs(t *testing.T) {
+	router := mux.NewRouter()
+	router.PathPrefix("/items/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	configs := getTestConfigs()
+	configs.MaxURLLength = 64
+
+	runTestServer(t, configs, router, true, nil, func(s Server) {
+		if resp := doRequest(t, "GET", configs.Port, "/items/"+strings.Repeat("a", 64), nil); resp.StatusCode != http.StatusRequestURITooLong {
+			t.Errorf("Expected: 414; Got: %d", resp.StatusCode)
+		}
+		if resp := doRequest(t, "GET", configs.Port, "/items/?q="+strings.Repeat("a", 64), nil); resp.StatusCode != http.StatusRequestURITooLong {
+			t.Errorf("Expected: 414 for a long query; Got: %d", resp.StatusCode)
+		}
+		testEndpoint(t, configs.Port, "/items/"+strings.Repeat("a", 57), 200)
+	})
+}